@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/fatih/color"
+	"github.com/xochilpili/subtitler-cli/internal/cache"
+	"github.com/xochilpili/subtitler-cli/internal/config"
+	files "github.com/xochilpili/subtitler-cli/internal/files"
+	"github.com/xochilpili/subtitler-cli/internal/flags"
+	"github.com/xochilpili/subtitler-cli/internal/logger"
+	"github.com/xochilpili/subtitler-cli/internal/menu"
+	"github.com/xochilpili/subtitler-cli/internal/scan"
+	"github.com/xochilpili/subtitler-cli/internal/server"
+	"github.com/xochilpili/subtitler-cli/internal/service"
+	"github.com/xochilpili/subtitler-cli/internal/subtitle"
+	"github.com/xochilpili/subtitler-cli/internal/worker"
+)
+
+// machineOutput keeps stdout for the results only when they are read by
+// another program.
+func machineOutput(settings *flags.OptionFlags) {
+	if !settings.Interactive() {
+		color.NoColor = true
+		logger.SetOutput(os.Stderr)
+	}
+}
+
+func runSearch(ctx context.Context, args []string) int {
+	settings, err := flags.ParseSearchFlags(args)
+	if err != nil {
+		return parseFailure(err)
+	}
+	machineOutput(settings)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	m, err := menu.New(settings)
+	if err != nil {
+		return fail(err)
+	}
+	if err := m.Search(ctx); err != nil {
+		// the interactive menu reports it and lets the search be retried, the
+		// tui has no way to search again
+		if settings.Auto || settings.TUI || !settings.Interactive() {
+			return fail(err)
+		}
+		logger.Error("%v %v", "error:", err.Error()+", type r to search again")
+	}
+	if settings.Auto {
+		if err := m.Auto(); err != nil {
+			return fail(err)
+		}
+		return exitOK
+	}
+	if !settings.Interactive() {
+		m.Print()
+		return exitOK
+	}
+	if settings.TUI {
+		if err := m.StartTUI(); err != nil {
+			return fail(err)
+		}
+		return exitOK
+	}
+	m.Start()
+	return exitOK
+}
+
+// runDownload downloads the given ids straight away, without the search
+// session nor the comments. Several ids go in a directory per id like the
+// menu does, so equally named files don't overwrite each other.
+func runDownload(ctx context.Context, args []string) int {
+	settings, err := flags.ParseDownloadFlags(args)
+	if err != nil {
+		return parseFailure(err)
+	}
+	machineOutput(settings)
+	provider, err := service.NewProvider(settings.Provider, settings)
+	if err != nil {
+		return fail(err)
+	}
+	formatter := service.NewFormatter(settings)
+	files, errs := worker.Map(ctx, settings.Concurrency, settings.Ids, func(ctx context.Context, id int) ([]files.Result, error) {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		path := settings.DownloadPath
+		if len(settings.Ids) > 1 {
+			path = filepath.Join(path, strconv.Itoa(id))
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return nil, err
+			}
+		}
+		return provider.DownloadSubtitle(ctx, id, path)
+	})
+	code := exitOK
+	var downloads []service.Download
+	for i, id := range settings.Ids {
+		if errs[i] != nil {
+			logger.Error("%v %v", fmt.Sprintf("error downloading %d:", id), errs[i].Error())
+			code = exitFailure
+			continue
+		}
+		downloads = append(downloads, service.NewDownload(id, files[i]))
+	}
+	formatter.FormatDownloadedFiles(downloads)
+	return code
+}
+
+func runComments(ctx context.Context, args []string) int {
+	settings, err := flags.ParseCommentsFlags(args)
+	if err != nil {
+		return parseFailure(err)
+	}
+	machineOutput(settings)
+	provider, err := service.NewProvider(settings.Provider, settings)
+	if err != nil {
+		return fail(err)
+	}
+	formatter := service.NewFormatter(settings)
+	code := exitOK
+	var subtitles []service.Subtitles
+	for _, id := range settings.Ids {
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		comments, err := provider.GetComments(ctx, id)
+		cancel()
+		if err != nil {
+			code = fail(err)
+			continue
+		}
+		subtitles = append(subtitles, service.Subtitles{Id: id, Comments: &comments})
+	}
+	formatter.FormatComments(subtitles)
+	return code
+}
+
+func runScan(ctx context.Context, args []string) int {
+	settings, err := flags.ParseScanFlags(args)
+	if err != nil {
+		return parseFailure(err)
+	}
+	provider, err := service.NewProvider(settings.Provider, settings)
+	if err != nil {
+		return fail(err)
+	}
+	if err := scan.New(settings, provider).Run(ctx); err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
+
+func runSync(ctx context.Context, args []string) int {
+	settings, err := flags.ParseSyncFlags(args)
+	if err != nil {
+		return parseFailure(err)
+	}
+	code := exitOK
+	for _, path := range settings.Files {
+		sub, format, err := subtitle.ReadFile(path, settings.FPS)
+		if err == nil {
+			err = sub.Sync(settings.Options)
+		}
+		output := path
+		if settings.Output != "" {
+			output = settings.Output
+		}
+		if err == nil {
+			err = sub.WriteFile(output, format, settings.FPS)
+		}
+		if err != nil {
+			code = exitFailure
+			logger.Error("%v %v", path+":", err.Error())
+			continue
+		}
+		logger.Info("%v: %v", "synced", output)
+	}
+	return code
+}
+
+func runConvert(ctx context.Context, args []string) int {
+	settings, err := flags.ParseConvertFlags(args)
+	if err != nil {
+		return parseFailure(err)
+	}
+	code := exitOK
+	for _, path := range settings.Files {
+		output := settings.Output
+		if output == "" {
+			output = strings.TrimSuffix(path, filepath.Ext(path)) + "." + string(settings.Format)
+		}
+		sub, _, err := subtitle.ReadFile(path, settings.FPS)
+		if err == nil && output == path {
+			err = errors.New("the subtitle is already " + string(settings.Format))
+		}
+		if err == nil {
+			err = sub.WriteFile(output, settings.Format, settings.FPS)
+		}
+		if err != nil {
+			code = exitFailure
+			logger.Error("%v %v", path+":", err.Error())
+			continue
+		}
+		logger.Info("%v: %v", "converted", output)
+	}
+	return code
+}
+
+func runServe(ctx context.Context, args []string) int {
+	settings, err := flags.ParseServeFlags(args)
+	if err != nil {
+		return parseFailure(err)
+	}
+	provider, err := service.NewProvider(settings.Provider, settings)
+	if err != nil {
+		return fail(err)
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := server.New(settings, provider).ListenAndServe(ctx, settings.Addr); err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
+
+func runConfig(ctx context.Context, args []string) int {
+	settings, err := flags.ParseConfigFlags(args)
+	if err != nil {
+		return parseFailure(err)
+	}
+	path, err := config.Path()
+	if err != nil {
+		return fail(err)
+	}
+	if settings.Action == flags.ConfigPath {
+		fmt.Println(path)
+		return exitOK
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return fail(err)
+	}
+	if settings.Action == flags.ConfigProfiles {
+		for _, name := range cfg.ProfileNames() {
+			fmt.Println(name)
+		}
+		return exitOK
+	}
+	resolved, err := cfg.Profile(settings.Profile)
+	if err != nil {
+		return fail(err)
+	}
+	if err := toml.NewEncoder(os.Stdout).Encode(resolved); err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
+
+func runCache(ctx context.Context, args []string) int {
+	settings, err := flags.ParseCacheFlags(args)
+	if err != nil {
+		return parseFailure(err)
+	}
+	dir, err := cache.Dir()
+	if err != nil {
+		return fail(err)
+	}
+	if settings.Action == flags.CacheClear {
+		if err := cache.New(dir, cache.DefaultTTL).Clear(); err != nil {
+			return fail(err)
+		}
+		logger.Info("%v: %v", "cache cleared", dir)
+		return exitOK
+	}
+	logger.Info("%v: %v", "cache directory", dir)
+	return exitOK
+}
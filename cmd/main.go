@@ -2,19 +2,103 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
 
 	"github.com/xochilpili/subtitler-cli/internal/flags"
-	"github.com/xochilpili/subtitler-cli/internal/menu"
+	"github.com/xochilpili/subtitler-cli/internal/logger"
 )
 
+// Exit codes of every subcommand.
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, args []string) int
+}
+
+var commands = []command{
+	{"search", "search subtitles and pick the ones to download (default)", runSearch},
+	{"download", "download subtitles by id", runDownload},
+	{"comments", "show the comments of subtitles by id", runComments},
+	{"scan", "download subtitles for every video in a directory", runScan},
+	{"sync", "shift, stretch or change the frame rate of subtitles", runSync},
+	{"convert", "convert subtitles to another format", runConvert},
+	{"serve", "serve the JSON http api", runServe},
+	{"config", "show the config file and its profiles", runConfig},
+	{"cache", "show or clear the on-disk cache", runCache},
+}
+
 func main() {
-	ctx := context.Background()
+	os.Exit(run(context.Background(), os.Args[1:]))
+}
 
-	flags := flags.ParseFlags()
+func run(ctx context.Context, args []string) int {
+	if len(args) < 1 {
+		usage(os.Stderr)
+		return exitUsage
+	}
+	name, args := args[0], args[1:]
+	switch {
+	case strings.HasPrefix(name, "-") && name != "-h" && name != "-help" && name != "--help":
+		// flags without a subcommand keep searching as before subcommands
+		name, args = "search", append([]string{name}, args...)
+	case name == "help" || name == "-h" || name == "-help" || name == "--help":
+		if len(args) < 1 {
+			usage(os.Stdout)
+			return exitOK
+		}
+		name, args = args[0], []string{"-h"}
+	}
 
-	primaryCtx, cancel := context.WithCancel(ctx)
-	defer cancel()
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd.run(ctx, args)
+		}
+	}
+	logError(errors.New("unknown command " + name))
+	usage(os.Stderr)
+	return exitUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "usage: subtitler <command> [flags] [args]\n\ncommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(w, "\nrun subtitler help <command> for its flags.\n")
+}
+
+// parseFailure reports an error of a flags parser, invalid flags print the
+// usage of the subcommand while config file errors are failures.
+func parseFailure(err error) int {
+	var usageErr *flags.UsageError
+	if !errors.As(err, &usageErr) {
+		return fail(err)
+	}
+	logError(err)
+	usageErr.Usage()
+	return exitUsage
+}
+
+// fail logs err and returns the failure exit code.
+func fail(err error) int {
+	logError(err)
+	return exitFailure
+}
 
-	m := menu.New(primaryCtx, flags)
-	m.Start()
+// logError logs a command error to stderr, the log messages may be going
+// to stdout along with the results.
+func logError(err error) {
+	previous := logger.SetOutput(os.Stderr)
+	logger.Error("%v %v", "error:", err.Error())
+	logger.SetOutput(previous)
 }
@@ -3,12 +3,14 @@ module github.com/xochilpili/subtitler-cli
 go 1.21.1
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/fatih/color v1.16.0
 	github.com/gen2brain/go-unarr v0.2.0
-	github.com/go-resty/resty/v2 v2.15.3
 	github.com/jedib0t/go-pretty/v6 v6.5.5
 	github.com/microcosm-cc/bluemonday v1.0.26
 	golang.org/x/net v0.27.0
+	golang.org/x/term v0.22.0
+	golang.org/x/text v0.16.0
 )
 
 require (
@@ -19,5 +21,4 @@ require (
 	github.com/mattn/go-runewidth v0.0.15 // indirect
 	github.com/rivo/uniseg v0.2.0 // indirect
 	golang.org/x/sys v0.22.0 // indirect
-	golang.org/x/text v0.16.0 // indirect
 )
@@ -0,0 +1,185 @@
+package cache
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	Searches = "searches"
+	Comments = "comments"
+	Archives = "archives"
+)
+
+// TTL is how long each kind of entry stays fresh.
+type TTL struct {
+	Search   time.Duration
+	Comments time.Duration
+	Archives time.Duration
+}
+
+var DefaultTTL = TTL{
+	Search:   time.Hour,
+	Comments: 6 * time.Hour,
+	Archives: 30 * 24 * time.Hour,
+}
+
+// Cache stores responses and downloaded archives on disk, a disabled cache
+// never finds anything and drops every write.
+type Cache struct {
+	dir      string
+	ttl      TTL
+	disabled bool
+}
+
+// Dir returns the cache directory, $XDG_CACHE_HOME/subtitler on linux.
+func Dir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "subtitler"), nil
+}
+
+// Open returns the cache in the default directory, it is disabled when
+// asked to or when there is no cache directory available.
+func Open(disabled bool, ttl TTL) *Cache {
+	dir, err := Dir()
+	if err != nil || disabled {
+		return &Cache{disabled: true}
+	}
+	return New(dir, ttl)
+}
+
+func New(dir string, ttl TTL) *Cache {
+	return &Cache{
+		dir: dir,
+		ttl: ttl,
+	}
+}
+
+// Get decodes the entry stored under key into target, it reports false when
+// there is no fresh entry.
+func (c *Cache) Get(bucket string, key string, target interface{}) bool {
+	path, ok := c.lookup(bucket, key, ".json")
+	if !ok {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, target) == nil
+}
+
+// Set stores value as json under key.
+func (c *Cache) Set(bucket string, key string, value interface{}) error {
+	if c.disabled {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return c.write(c.path(bucket, key, ".json"), func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// Archive returns the path of the cached archive for key.
+func (c *Cache) Archive(key string) (string, bool) {
+	if c.disabled {
+		return "", false
+	}
+	matches, _ := filepath.Glob(c.path(Archives, key, ".*"))
+	for _, match := range matches {
+		if c.fresh(match, c.ttl.Archives) {
+			return match, true
+		}
+	}
+	return "", false
+}
+
+// StoreArchive copies the archive at source into the cache under key,
+// keeping its extension so it can be opened again.
+func (c *Cache) StoreArchive(key string, source string) error {
+	if c.disabled {
+		return nil
+	}
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return c.write(c.path(Archives, key, filepath.Ext(source)), func(w io.Writer) error {
+		_, err := io.Copy(w, in)
+		return err
+	})
+}
+
+// Clear removes every cached entry.
+func (c *Cache) Clear() error {
+	if c.dir == "" {
+		return nil
+	}
+	return os.RemoveAll(c.dir)
+}
+
+func (c *Cache) lookup(bucket string, key string, ext string) (string, bool) {
+	if c.disabled {
+		return "", false
+	}
+	path := c.path(bucket, key, ext)
+	return path, c.fresh(path, c.bucketTTL(bucket))
+}
+
+func (c *Cache) fresh(path string, ttl time.Duration) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return time.Since(info.ModTime()) < ttl
+}
+
+func (c *Cache) bucketTTL(bucket string) time.Duration {
+	switch bucket {
+	case Searches:
+		return c.ttl.Search
+	case Comments:
+		return c.ttl.Comments
+	}
+	return c.ttl.Archives
+}
+
+func (c *Cache) path(bucket string, key string, ext string) string {
+	sum := sha1.Sum([]byte(key))
+	return filepath.Join(c.dir, bucket, hex.EncodeToString(sum[:])+ext)
+}
+
+// write replaces path atomically so concurrent readers never see a partial
+// entry.
+func (c *Cache) write(path string, fn func(w io.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if err := fn(tmp); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
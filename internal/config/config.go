@@ -0,0 +1,121 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Settings are the defaults a config file or one of its profiles can set,
+// empty values are left to the flags defaults.
+type Settings struct {
+	DownloadPath string   `toml:"download_path"`
+	Releases     []string `toml:"releases"`
+	Style        string   `toml:"style"`
+	Provider     string   `toml:"provider"`
+	Output       string   `toml:"output"`
+	Language     string   `toml:"language"`
+	Concurrency  int      `toml:"concurrency"`
+}
+
+// Config is the content of the config file, e.g.
+//
+//	releases = ["FLUX", "NTb"]
+//	download_path = "~/Videos"
+//
+//	[profiles.anime]
+//	releases = ["SubsPlease", "Erai-raws"]
+//	language = "es"
+type Config struct {
+	Settings
+	Profiles map[string]Settings `toml:"profiles"`
+}
+
+// Path returns the config file location, SUBTITLER_CONFIG overrides the
+// default ~/.config/subtitler/config.toml.
+func Path() (string, error) {
+	if path := os.Getenv("SUBTITLER_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "subtitler", "config.toml"), nil
+}
+
+// Load reads the config file at path, a missing file is an empty config.
+func Load(path string) (*Config, error) {
+	var config Config
+	_, err := toml.DecodeFile(path, &config)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading config %s: %v", path, err)
+	}
+	return &config, nil
+}
+
+// Profile returns the top level settings overridden by the named profile,
+// an empty name returns the top level settings alone.
+func (c *Config) Profile(name string) (Settings, error) {
+	settings := c.Settings
+	if name == "" {
+		return settings, nil
+	}
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return settings, fmt.Errorf("unknown profile %q, available: %v", name, c.ProfileNames())
+	}
+	if profile.DownloadPath != "" {
+		settings.DownloadPath = profile.DownloadPath
+	}
+	if len(profile.Releases) > 0 {
+		settings.Releases = profile.Releases
+	}
+	if profile.Style != "" {
+		settings.Style = profile.Style
+	}
+	if profile.Provider != "" {
+		settings.Provider = profile.Provider
+	}
+	if profile.Output != "" {
+		settings.Output = profile.Output
+	}
+	if profile.Language != "" {
+		settings.Language = profile.Language
+	}
+	if profile.Concurrency > 0 {
+		settings.Concurrency = profile.Concurrency
+	}
+	return settings, nil
+}
+
+// ProfileNames returns the sorted names of the profiles.
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ExpandHome replaces a leading ~ with the home directory.
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
@@ -0,0 +1,223 @@
+package file
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gen2brain/go-unarr"
+	"github.com/xochilpili/subtitler-cli/internal/subtitle"
+)
+
+// Extraction limits of a single download, nested archives included.
+const (
+	maxDepth     = 3
+	maxEntries   = 1000
+	maxEntrySize = 20 << 20
+	maxTotalSize = 100 << 20
+)
+
+var (
+	// ErrUnsafeEntry is an entry whose name escapes the extraction directory.
+	ErrUnsafeEntry = errors.New("unsafe archive entry")
+	// ErrTooLarge is an archive over the size, file count or nesting limits.
+	ErrTooLarge = errors.New("archive exceeds the extraction limits")
+	// ErrUnknownArchive is a download that is neither an archive nor a subtitle.
+	ErrUnknownArchive = errors.New("unknown archive format")
+)
+
+type kind string
+
+const (
+	kindZip     kind = "zip"
+	kindRar     kind = "rar"
+	kind7z      kind = "7z"
+	kindText    kind = "text"
+	kindUnknown kind = ""
+)
+
+var magics = []struct {
+	kind  kind
+	magic []byte
+}{
+	{kindZip, []byte("PK\x03\x04")},
+	{kindZip, []byte("PK\x05\x06")},
+	{kindRar, []byte("Rar!\x1a\x07")},
+	{kind7z, []byte("7z\xbc\xaf\x27\x1c")},
+}
+
+// detectKind guesses the kind of data from its first bytes, text with a BOM,
+// UTF-16 text or text without NUL bytes is taken as a subtitle served
+// without an archive.
+func detectKind(data []byte) kind {
+	for _, m := range magics {
+		if bytes.HasPrefix(data, m.magic) {
+			return m.kind
+		}
+	}
+	if len(data) == 0 {
+		return kindUnknown
+	}
+	if detection := DetectEncoding(data); detection.BOM || isUTF16(detection.Encoding) {
+		return kindText
+	}
+	if !bytes.Contains(data[:min(len(data), 4096)], []byte{0}) {
+		return kindText
+	}
+	return kindUnknown
+}
+
+func isArchive(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".zip", ".rar", ".7z":
+		return true
+	}
+	return false
+}
+
+// safeName reports whether an entry name stays inside the directory it is
+// extracted to.
+func safeName(name string) bool {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if path.IsAbs(name) || filepath.VolumeName(name) != "" || (len(name) > 1 && name[1] == ':') {
+		return false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// budget tracks what an extraction used so far.
+type budget struct {
+	entries int
+	size    int
+}
+
+// readHeader returns the first bytes of filename, enough to detect its kind.
+func readHeader(filename string) ([]byte, error) {
+	header := make([]byte, 4096)
+	in, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	n, err := io.ReadFull(in, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return header[:n], nil
+}
+
+// ArchiveExt returns the extension matching the content of a downloaded
+// file, or "" when it is neither an archive nor text.
+func ArchiveExt(filename string) (string, error) {
+	header, err := readHeader(filename)
+	if err != nil {
+		return "", err
+	}
+	switch k := detectKind(header); k {
+	case kindZip, kindRar, kind7z:
+		return "." + string(k), nil
+	case kindText:
+		return ".txt", nil
+	}
+	return "", nil
+}
+
+// walk calls fn with the name and content of every subtitle in the
+// downloaded file, looking into nested archives.
+func (f *file) walk(fn func(name string, data []byte) error) error {
+	header, err := readHeader(f.filePath)
+	if err != nil {
+		return err
+	}
+
+	switch detectKind(header) {
+	case kindText:
+		data, err := os.ReadFile(f.filePath)
+		if err != nil {
+			return err
+		}
+		if len(data) > maxEntrySize {
+			return ErrTooLarge
+		}
+		format, err := subtitle.Detect(f.filePath, decodeText(data))
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrUnknownArchive, filepath.Base(f.filePath))
+		}
+		name := strings.TrimSuffix(filepath.Base(f.filePath), filepath.Ext(f.filePath)) + "." + string(format)
+		return fn(name, data)
+	case kindUnknown:
+		return fmt.Errorf("%w: %s", ErrUnknownArchive, filepath.Base(f.filePath))
+	}
+
+	a, err := unarr.NewArchive(f.filePath)
+	if err != nil {
+		return fmt.Errorf("error while opening compressed file: %v", err)
+	}
+	defer a.Close()
+	return walkArchive(a, 0, &budget{}, fn)
+}
+
+func walkArchive(a *unarr.Archive, depth int, b *budget, fn func(name string, data []byte) error) error {
+	for {
+		err := a.Entry()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading archive: %v", err)
+		}
+
+		if !safeName(a.RawName()) {
+			return fmt.Errorf("%w: %s", ErrUnsafeEntry, a.RawName())
+		}
+		b.entries++
+		if b.entries > maxEntries {
+			return fmt.Errorf("%w: more than %d files", ErrTooLarge, maxEntries)
+		}
+		name := a.Name()
+		nested := isArchive(name)
+		if !isSubtitle(name) && !nested {
+			continue
+		}
+		if a.Size() > maxEntrySize || b.size+a.Size() > maxTotalSize {
+			return fmt.Errorf("%w: %s is too large", ErrTooLarge, name)
+		}
+		data, err := a.ReadAll()
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", name, err)
+		}
+		b.size += len(data)
+
+		if !nested {
+			if err := fn(name, data); err != nil {
+				return err
+			}
+			continue
+		}
+		if k := detectKind(data); k != kindZip && k != kindRar && k != kind7z {
+			continue
+		}
+		if depth+1 >= maxDepth {
+			return fmt.Errorf("%w: archives nested more than %d levels", ErrTooLarge, maxDepth)
+		}
+		inner, err := unarr.NewArchiveFromMemory(data)
+		if err != nil {
+			return fmt.Errorf("error while opening nested archive %s: %v", name, err)
+		}
+		err = walkArchive(inner, depth+1, b, fn)
+		inner.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package file
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"golang.org/x/text/encoding/unicode"
+)
+
+const srt = "1\n00:00:01,000 --> 00:00:02,000\nHola\n\n"
+
+func TestSafeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"movie.srt", true},
+		{"subs/movie.srt", true},
+		{"subs\\movie.srt", true},
+		{"..movie.srt", true},
+		{"../movie.srt", false},
+		{"subs/../../movie.srt", false},
+		{"subs\\..\\..\\movie.srt", false},
+		{"/etc/movie.srt", false},
+		{"C:\\movie.srt", false},
+		{"c:movie.srt", false},
+	}
+	for _, tt := range tests {
+		if got := safeName(tt.name); got != tt.want {
+			t.Errorf("safeName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDetectKind(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want kind
+	}{
+		{"zip", []byte("PK\x03\x04rest"), kindZip},
+		{"rar", []byte("Rar!\x1a\x07\x00"), kindRar},
+		{"7z", []byte("7z\xbc\xaf\x27\x1c\x00"), kind7z},
+		{"subtitle", []byte(srt), kindText},
+		{"utf-16le subtitle", utf16Of(t, "\xff\xfe", unicode.LittleEndian, srt), kindText},
+		{"utf-16be subtitle", utf16Of(t, "\xfe\xff", unicode.BigEndian, srt), kindText},
+		{"utf-16le subtitle without bom", utf16Of(t, "", unicode.LittleEndian, srt), kindText},
+		{"binary", []byte("\x00\x01\x02"), kindUnknown},
+		{"empty", nil, kindUnknown},
+	}
+	for _, tt := range tests {
+		if got := detectKind(tt.data); got != tt.want {
+			t.Errorf("detectKind(%s) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestArchiveExt(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"zip", zipOf(t, "Movie.srt", srt), ".zip"},
+		{"rar", []byte("Rar!\x1a\x07\x00"), ".rar"},
+		{"subtitle", []byte(srt), ".txt"},
+		{"binary", []byte("\x00\x01\x02"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "1.bin")
+			if err := os.WriteFile(filename, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ArchiveExt(filename)
+			if err != nil || got != tt.want {
+				t.Errorf("ArchiveExt() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+// utf16Of returns text encoded as UTF-16 after bom.
+func utf16Of(t *testing.T, bom string, endianness unicode.Endianness, text string) []byte {
+	return append([]byte(bom), encode(t, unicode.UTF16(endianness, unicode.IgnoreBOM), text)...)
+}
+
+// zipOf returns a zip archive holding the given name/content pairs.
+func zipOf(t *testing.T, entries ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for i := 0; i < len(entries); i += 2 {
+		f, err := w.Create(entries[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(entries[i+1])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessSubtitles(t *testing.T) {
+	nested := string(zipOf(t, "inner/Movie.en.srt", srt))
+	tooDeep := string(zipOf(t, "a.zip", string(zipOf(t, "b.zip", string(zipOf(t, "c.zip", nested))))))
+	tests := []struct {
+		name     string
+		download string
+		data     []byte
+		want     []string
+		wantErr  error
+	}{
+		{
+			name:     "zip with nested zip",
+			download: "1.zip",
+			data:     zipOf(t, "Movie.es.srt", srt, "readme.txt", "hola", "more.zip", nested),
+			want:     []string{"Movie.en.srt", "Movie.es.srt"},
+		},
+		{
+			name:     "unparseable subtitles are skipped",
+			download: "1.zip",
+			data:     zipOf(t, "Movie.srt", srt, "Broken.srt", "not a subtitle", "Empty.srt", ""),
+			want:     []string{"Movie.srt"},
+		},
+		{
+			name:     "subtitle without archive",
+			download: "1.bin",
+			data:     []byte(srt),
+			want:     []string{"1.srt"},
+		},
+		{
+			name:     "utf-16 subtitle without archive",
+			download: "1.bin",
+			data:     utf16Of(t, "\xff\xfe", unicode.LittleEndian, srt),
+			want:     []string{"1.srt"},
+		},
+		{
+			name:     "path traversal",
+			download: "1.zip",
+			data:     zipOf(t, "../../Movie.srt", srt),
+			wantErr:  ErrUnsafeEntry,
+		},
+		{
+			name:     "nested too deep",
+			download: "1.zip",
+			data:     []byte(tooDeep),
+			wantErr:  ErrTooLarge,
+		},
+		{
+			name:     "unknown download",
+			download: "1.bin",
+			data:     []byte("\x00\x01\x02\x03"),
+			wantErr:  ErrUnknownArchive,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			download := filepath.Join(dir, tt.download)
+			if err := os.WriteFile(download, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			results, err := New(download).ProcessSubtitles(dir, true)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ProcessSubtitles() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, result := range results {
+				got = append(got, filepath.Base(result.Path))
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+			// only the subtitles are left in the download directory
+			entries, _ := os.ReadDir(dir)
+			if len(entries) != len(tt.want) {
+				t.Errorf("download directory has %d entries, want %d", len(entries), len(tt.want))
+			}
+		})
+	}
+}
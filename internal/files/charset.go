@@ -0,0 +1,189 @@
+package file
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Encoding names as reported by the detection.
+const (
+	EncodingUTF8    = "utf-8"
+	EncodingUTF16LE = "utf-16le"
+	EncodingUTF16BE = "utf-16be"
+	EncodingCP1252  = "windows-1252"
+	EncodingLatin1  = "iso-8859-1"
+	EncodingLatin9  = "iso-8859-15"
+)
+
+// Detection is the encoding a subtitle was found in, Confidence goes from 0
+// to 1 and is 1 for a BOM.
+type Detection struct {
+	Encoding   string  `json:"encoding"`
+	Confidence float64 `json:"confidence"`
+	BOM        bool    `json:"bom,omitempty"`
+}
+
+var boms = []struct {
+	bom      []byte
+	encoding string
+}{
+	{[]byte("\xef\xbb\xbf"), EncodingUTF8},
+	{[]byte("\xff\xfe"), EncodingUTF16LE},
+	{[]byte("\xfe\xff"), EncodingUTF16BE},
+}
+
+// singleByte are the candidates for text that isn't unicode, in order of
+// preference when they decode the text the same way. Windows-1252 only wins
+// over ISO-8859-1 when the text uses its quotes or ellipsis.
+var singleByte = []struct {
+	name     string
+	encoding encoding.Encoding
+}{
+	{EncodingLatin1, charmap.ISO8859_1},
+	{EncodingCP1252, charmap.Windows1252},
+	{EncodingLatin9, charmap.ISO8859_15},
+}
+
+// spanishRunes are the non ASCII characters expected in spanish subtitles,
+// accented vowels, ñ, opening marks and typographic punctuation.
+const spanishRunes = "áéíóúüñÁÉÍÓÚÜÑ¿¡àèìòùçÀÈÌÒÙÇâêîôûºª«»€…“”‘’–—·"
+
+// DetectEncoding finds the encoding of a subtitle: a BOM first, then UTF-16
+// by its NUL bytes, valid UTF-8, and last the single byte encoding whose
+// decoded text looks the most like spanish.
+func DetectEncoding(data []byte) Detection {
+	for _, b := range boms {
+		if bytes.HasPrefix(data, b.bom) {
+			return Detection{Encoding: b.encoding, Confidence: 1, BOM: true}
+		}
+	}
+	if encoding, confidence := detectUTF16(data); confidence > 0.5 {
+		return Detection{Encoding: encoding, Confidence: confidence}
+	}
+	if utf8.Valid(data) {
+		return Detection{Encoding: EncodingUTF8, Confidence: 1}
+	}
+
+	best := Detection{Encoding: EncodingCP1252, Confidence: -1}
+	for _, candidate := range singleByte {
+		decoded, err := candidate.encoding.NewDecoder().Bytes(data)
+		if err != nil {
+			continue
+		}
+		if confidence := spanishScore(string(decoded)); confidence > best.Confidence {
+			best = Detection{Encoding: candidate.name, Confidence: confidence}
+		}
+	}
+	return best
+}
+
+func trimBOM(data []byte) []byte {
+	for _, b := range boms {
+		if bytes.HasPrefix(data, b.bom) {
+			return data[len(b.bom):]
+		}
+	}
+	return data
+}
+
+func isUTF16(name string) bool {
+	return name == EncodingUTF16LE || name == EncodingUTF16BE
+}
+
+// decodeText returns data without its BOM and decoded to UTF-8 when it is
+// UTF-16, to look at a subtitle before its charset is fixed.
+func decodeText(data []byte) []byte {
+	detection := DetectEncoding(data)
+	text := trimBOM(data)
+	if !isUTF16(detection.Encoding) {
+		return text
+	}
+	enc, _ := lookupEncoding(detection.Encoding)
+	decoded, err := enc.NewDecoder().Bytes(text)
+	if err != nil {
+		return text
+	}
+	return decoded
+}
+
+// detectUTF16 looks for the NUL half of ASCII characters in UTF-16 text
+// without a BOM, the confidence is the share of NULs on that side.
+func detectUTF16(data []byte) (string, float64) {
+	sample := data[:min(len(data), 4096)]
+	if len(sample) < 4 {
+		return "", 0
+	}
+	var even, odd int
+	for i, b := range sample {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			even++
+		} else {
+			odd++
+		}
+	}
+	pairs := float64(len(sample) / 2)
+	if odd > even {
+		return EncodingUTF16LE, float64(odd-even) / pairs
+	}
+	return EncodingUTF16BE, float64(even-odd) / pairs
+}
+
+// spanishScore rates decoded text by its non ASCII characters: expected
+// ones count for it, control characters and symbols against it.
+func spanishScore(text string) float64 {
+	var good, bad int
+	for _, r := range text {
+		switch {
+		case r < 0x80:
+			continue
+		case strings.ContainsRune(spanishRunes, r):
+			good++
+		case r < 0xa0 || r == utf8.RuneError:
+			// C1 controls only appear when the wrong table is used
+			bad += 2
+		default:
+			bad++
+		}
+	}
+	if good+bad == 0 {
+		return 1
+	}
+	return float64(good) / float64(good+bad)
+}
+
+// lookupEncoding returns the decoder of a detected or given encoding name.
+// The detected single byte names are decoded with their own tables, the
+// WHATWG labels of charset.Lookup read iso-8859-1 as windows-1252.
+func lookupEncoding(name string) (encoding.Encoding, bool) {
+	switch name {
+	case EncodingUTF8:
+		return unicode.UTF8, true
+	case EncodingUTF16LE:
+		return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), true
+	case EncodingUTF16BE:
+		return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), true
+	case EncodingLatin1, "latin1":
+		return charmap.ISO8859_1, true
+	case EncodingCP1252:
+		return charmap.Windows1252, true
+	case EncodingLatin9:
+		return charmap.ISO8859_15, true
+	}
+	e, _ := charset.Lookup(name)
+	return e, e != nil
+}
+
+// ValidEncoding reports whether name is an encoding -encoding accepts.
+func ValidEncoding(name string) bool {
+	_, ok := lookupEncoding(strings.ToLower(name))
+	return ok
+}
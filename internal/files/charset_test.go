@@ -0,0 +1,169 @@
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const spanish = "1\n00:00:01,000 --> 00:00:02,000\n¿Qué pasó, señor? ¡Adiós!\n\n"
+
+func encode(t *testing.T, enc encoding.Encoding, text string) []byte {
+	t.Helper()
+	data, err := enc.NewEncoder().Bytes([]byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDetectEncoding(t *testing.T) {
+	utf16le := unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	utf16be := unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+	tests := []struct {
+		name string
+		data []byte
+		want Detection
+	}{
+		{"utf-8", []byte(spanish), Detection{Encoding: EncodingUTF8, Confidence: 1}},
+		{"ascii", []byte("Hello"), Detection{Encoding: EncodingUTF8, Confidence: 1}},
+		{"utf-8 bom", append([]byte("\xef\xbb\xbf"), spanish...), Detection{Encoding: EncodingUTF8, Confidence: 1, BOM: true}},
+		{"utf-16le bom", append([]byte("\xff\xfe"), encode(t, utf16le, spanish)...), Detection{Encoding: EncodingUTF16LE, Confidence: 1, BOM: true}},
+		{"utf-16be bom", append([]byte("\xfe\xff"), encode(t, utf16be, spanish)...), Detection{Encoding: EncodingUTF16BE, Confidence: 1, BOM: true}},
+		{"latin-1", encode(t, charmap.ISO8859_1, spanish), Detection{Encoding: EncodingLatin1, Confidence: 1}},
+		{"windows-1252 quotes", encode(t, charmap.Windows1252, "“Qué” dijo… ¡sí!"), Detection{Encoding: EncodingCP1252, Confidence: 1}},
+		{"latin-9 euro", encode(t, charmap.ISO8859_15, "Cuesta 5€, señor"), Detection{Encoding: EncodingLatin9, Confidence: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectEncoding(tt.data); got != tt.want {
+				t.Errorf("DetectEncoding() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectUTF16WithoutBOM(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"little endian", encode(t, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), spanish), EncodingUTF16LE},
+		{"big endian", encode(t, unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), spanish), EncodingUTF16BE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectEncoding(tt.data)
+			if got.Encoding != tt.want || got.BOM || got.Confidence <= 0.5 {
+				t.Errorf("DetectEncoding() = %+v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidEncoding(t *testing.T) {
+	for _, name := range []string{"utf-8", "UTF-16LE", "windows-1252", "latin1", "ISO-8859-15"} {
+		if !ValidEncoding(name) {
+			t.Errorf("ValidEncoding(%q) = false", name)
+		}
+	}
+	for _, name := range []string{"", "klingon"} {
+		if ValidEncoding(name) {
+			t.Errorf("ValidEncoding(%q) = true", name)
+		}
+	}
+}
+
+func TestLookupEncoding(t *testing.T) {
+	// 0x93 is a control character in iso-8859-1 and a quote in windows-1252,
+	// 0xa4 is the euro sign only in iso-8859-15
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{EncodingLatin1, "\x93\xa4", "\u0093¤"},
+		{"latin1", "\x93\xa4", "\u0093¤"},
+		{EncodingCP1252, "\x93\xa4", "“¤"},
+		{EncodingLatin9, "\xa4", "€"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc, ok := lookupEncoding(tt.name)
+			if !ok {
+				t.Fatalf("lookupEncoding(%q) not found", tt.name)
+			}
+			decoded, err := enc.NewDecoder().String(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decoded != tt.want {
+				t.Errorf("decoded %q, want %q", decoded, tt.want)
+			}
+		})
+	}
+}
+
+func TestFixCharset(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		override string
+		want     Detection
+		content  string
+	}{
+		{
+			name:    "windows-1252 is rewritten",
+			data:    encode(t, charmap.Windows1252, "“Qué” dijo… ¡sí!"),
+			want:    Detection{Encoding: EncodingCP1252, Confidence: 1},
+			content: "“Qué” dijo… ¡sí!",
+		},
+		{
+			name:    "utf-16 bom is dropped",
+			data:    append([]byte("\xff\xfe"), encode(t, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), spanish)...),
+			want:    Detection{Encoding: EncodingUTF16LE, Confidence: 1, BOM: true},
+			content: spanish,
+		},
+		{
+			name:    "utf-8 bom is untouched",
+			data:    append([]byte("\xef\xbb\xbf"), spanish...),
+			want:    Detection{Encoding: EncodingUTF8, Confidence: 1, BOM: true},
+			content: "\xef\xbb\xbf" + spanish,
+		},
+		{
+			name:     "override keeps the detection",
+			data:     []byte("Qu\xc3\xa9"),
+			override: EncodingLatin1,
+			want:     Detection{Encoding: EncodingUTF8, Confidence: 1},
+			content:  "QuÃ©",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "movie.srt")
+			if err := os.WriteFile(path, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			f := New(path)
+			if tt.override != "" {
+				f.SetEncoding(tt.override)
+			}
+			got, err := f.fixCharset(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("fixCharset() = %+v, want %+v", got, tt.want)
+			}
+			content, _ := os.ReadFile(path)
+			if string(content) != tt.content {
+				t.Errorf("content = %q, want %q", content, tt.content)
+			}
+		})
+	}
+}
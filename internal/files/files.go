@@ -1,7 +1,6 @@
 package file
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
 	"io"
@@ -9,17 +8,40 @@ import (
 	"path/filepath"
 	"strings"
 
-	"github.com/gen2brain/go-unarr"
-	"golang.org/x/net/html/charset"
+	"github.com/xochilpili/subtitler-cli/internal/logger"
+	"github.com/xochilpili/subtitler-cli/internal/media"
+	"github.com/xochilpili/subtitler-cli/internal/subtitle"
 )
 
 type File interface {
-	ListFiles() []string
-	ProcessSubtitles(path string) error
+	ListFiles() ([]string, error)
+	ProcessSubtitles(path string, clean bool) ([]Result, error)
+}
+
+// Result is a subtitle written by ProcessSubtitles with the encoding it was
+// detected in, the one given instead of it if any and the one it was
+// written in.
+type Result struct {
+	Path string `json:"path"`
+	Detection
+	Override string `json:"override,omitempty"`
+	Written  string `json:"written"`
+}
+
+// ReadAs returns the encoding the subtitle was decoded with.
+func (r Result) ReadAs() string {
+	if r.Override != "" {
+		return r.Override
+	}
+	return r.Encoding
 }
 
 type file struct {
 	filePath string
+	episode  *media.Episode
+	format   subtitle.Format
+	fps      float64
+	encoding string
 }
 
 func New(filePath string) *file {
@@ -28,125 +50,179 @@ func New(filePath string) *file {
 	}
 }
 
-func (f *file) ListFiles() []string {
-	a, err := unarr.NewArchive(f.filePath)
+// SetEpisode restricts the subtitles taken out of the archive to a single
+// episode, so only the requested one is extracted from a season pack.
+func (f *file) SetEpisode(episode media.Episode) *file {
+	f.episode = &episode
+	return f
+}
 
-	if err != nil {
-		panic(fmt.Errorf("error while opening compressed file: %v", err))
-	}
-	defer a.Close()
-	list, err := a.List()
+// SetFormat converts every processed subtitle to format, fps is used for
+// frame based formats.
+func (f *file) SetFormat(format subtitle.Format, fps float64) *file {
+	f.format = format
+	f.fps = fps
+	return f
+}
+
+// SetEncoding reads every subtitle as encoding instead of detecting it.
+func (f *file) SetEncoding(encoding string) *file {
+	f.encoding = strings.ToLower(encoding)
+	return f
+}
 
+// ListFiles returns the subtitles in the archive, nested archives included,
+// restricted to the episode when one is set.
+func (f *file) ListFiles() ([]string, error) {
+	var subtitleFiles []string
+	err := f.walk(func(name string, data []byte) error {
+		subtitleFiles = append(subtitleFiles, name)
+		return nil
+	})
 	if err != nil {
-		panic(fmt.Errorf("error listing file contents: %v", err))
+		return nil, err
 	}
+	return f.episodeFiles(subtitleFiles), nil
+}
 
-	// TODO: get only srt and ssa files.
-	var subtitleFiles []string
-	for _, item := range list {
-		position := strings.LastIndex(item, ".")
-		if position > -1 {
-			extensionFile := item[position+1:]
-			if extensionFile == "srt" || extensionFile == "ssa" {
-				subtitleFiles = append(subtitleFiles, item)
-			}
+// episodeFiles keeps the names tagged with the episode, or all of them when
+// none is.
+func (f *file) episodeFiles(names []string) []string {
+	if f.episode == nil {
+		return names
+	}
+	var episodeFiles []string
+	for _, item := range names {
+		if episode, ok := media.ParseEpisode(filepath.Base(item)); ok && episode == *f.episode {
+			episodeFiles = append(episodeFiles, item)
 		}
 	}
-	return subtitleFiles
+	// single episode archives usually don't tag their files, keep them all
+	if len(episodeFiles) < 1 {
+		return names
+	}
+	return episodeFiles
 }
 
-func (f *file) ProcessSubtitles(path string, clean bool) ([]*string, error) {
-	// Extract files from compressed downloaded file.
-	extractedFiles, err := f.extract(path)
+// ProcessSubtitles extracts the subtitles of the archive into a temporary
+// directory inside path, fixes their charset, converts them when a format
+// is set and moves the ones that parse into path. Nothing but subtitles is
+// ever written to path.
+func (f *file) ProcessSubtitles(path string, clean bool) ([]Result, error) {
+	tmp, err := os.MkdirTemp(path, ".subtitler-")
 	if err != nil {
 		return nil, err
 	}
-	// Iterate over each extracted file and
-	// determine if it's a subtitle based on it's extension
-	// if it's not a subtitle the file will be removed
-	// otherwise if it's a subtitle then validate encoded charset
-	// and create a new file in UTF-8 encode
-	var result []*string
-	for _, subtitle := range extractedFiles {
-		subtitlePath := filepath.Join(path, subtitle)
-		extension := filepath.Ext(subtitlePath)
-		if extension == ".srt" || extension == ".ssa" {
-			err := f.fixCharset(subtitlePath)
-			if err != nil {
-				if errors.Is(err, io.EOF) {
-					os.Remove(subtitlePath)
-					continue
-				}
-				return nil, err
+	defer os.RemoveAll(tmp)
+
+	extracted := map[string]string{}
+	var names []string
+	err = f.walk(func(name string, data []byte) error {
+		target := uniqueName(tmp, filepath.Base(name))
+		if err := os.WriteFile(target, data, 0644); err != nil {
+			return err
+		}
+		extracted[name] = target
+		names = append(names, name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	fps := f.fps
+	if fps <= 0 {
+		fps = subtitle.DefaultFPS
+	}
+	var result []Result
+	for _, name := range f.episodeFiles(names) {
+		subtitlePath := extracted[name]
+		detection, err := f.fixCharset(subtitlePath)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				continue
 			}
+			return nil, err
+		}
 
-			os.Rename(subtitlePath+".utf8", subtitlePath)
-			result = append(result, &subtitlePath)
-		} else {
-			if _, err := os.Stat(subtitlePath); err == nil {
-				err := os.Remove(subtitlePath)
-				if err != nil {
-					return nil, err
-				}
+		// vobsub .sub files and broken subtitles don't parse, leave them out
+		if _, _, err := subtitle.ReadFile(subtitlePath, fps); err != nil {
+			continue
+		}
+		if f.format != "" {
+			converted, err := subtitle.ConvertFile(subtitlePath, f.format, fps)
+			if err != nil {
+				return nil, fmt.Errorf("error converting %s: %v", filepath.Base(subtitlePath), err)
 			}
+			subtitlePath = converted
 		}
+		target := filepath.Join(path, filepath.Base(subtitlePath))
+		if err := os.Rename(subtitlePath, target); err != nil {
+			return nil, err
+		}
+		result = append(result, Result{Path: target, Detection: detection, Override: f.encoding, Written: EncodingUTF8})
 	}
 	// remove compressed source file
 	if clean {
 		err := os.Remove(f.filePath)
 		if err != nil {
-			fmt.Printf("error while removing source %s, %v", f.filePath, err)
+			logger.Error("%v %v", "error while removing source "+f.filePath+":", err.Error())
 		}
 	}
 
 	return result, nil
 }
 
-func (f *file) extract(path string) ([]string, error) {
-	a, err := unarr.NewArchive(f.filePath)
-	if err != nil {
-		panic(fmt.Errorf("unable to open file: %v", err))
-	}
-	defer a.Close()
-	return a.Extract(path)
+// isSubtitle reports whether name has one of the subtitle extensions the
+// subtitle package can read.
+func isSubtitle(name string) bool {
+	_, err := subtitle.ParseFormat(filepath.Ext(name))
+	return err == nil
 }
 
-func (f *file) fixCharset(filename string) error {
-	inputFile, err := os.Open(filename)
-	if err != nil {
-		return err
+// uniqueName returns dir/name, numbered when an entry of another folder of
+// the archive already took the name.
+func uniqueName(dir string, name string) string {
+	target := filepath.Join(dir, name)
+	ext := filepath.Ext(name)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(target); os.IsNotExist(err) {
+			return target
+		}
+		target = filepath.Join(dir, fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), i, ext))
 	}
-	defer inputFile.Close()
+}
 
-	// determine encoding of the file
-	charsetReader, err := charset.NewReader(inputFile, "")
+// fixCharset rewrites filename as UTF-8 from the given encoding or the
+// detected one and returns the detection either way, UTF-8 files are left
+// untouched. An empty file is io.EOF.
+func (f *file) fixCharset(filename string) (Detection, error) {
+	data, err := os.ReadFile(filename)
 	if err != nil {
-		return err
+		return Detection{}, err
+	}
+	if len(data) == 0 {
+		return Detection{}, io.EOF
 	}
 
-	// Couldn't validate which charset the file is
-	// therefor, every file will be encoded to UTF-8 :/
-
-	// Read content with determined encoding
-	scanner := bufio.NewScanner(charsetReader)
-	scanner.Split(bufio.ScanLines)
-	var lines []string
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+	detection := DetectEncoding(data)
+	name := detection.Encoding
+	if f.encoding != "" {
+		name = f.encoding
 	}
-	// connvert text to UTF-8
-	utf8Bytes := []byte{}
-	for _, line := range lines {
-		utf8Bytes = append(utf8Bytes, []byte(line)...)
-		utf8Bytes = append(utf8Bytes, '\n')
+	if name == EncodingUTF8 {
+		return detection, nil
 	}
-
-	// Write the UTF-8 content to a new file
-	outputFile := filename + ".utf8"
-	err = os.WriteFile(outputFile, utf8Bytes, 0644)
+	enc, ok := lookupEncoding(name)
+	if !ok {
+		return detection, fmt.Errorf("unknown encoding %s", name)
+	}
+	if detection.BOM {
+		data = trimBOM(data)
+	}
+	decoded, err := enc.NewDecoder().Bytes(data)
 	if err != nil {
-		return err
+		return detection, fmt.Errorf("error decoding %s as %s: %v", filepath.Base(filename), name, err)
 	}
-
-	return nil
+	return detection, os.WriteFile(filename, decoded, 0644)
 }
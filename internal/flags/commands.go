@@ -0,0 +1,284 @@
+package flags
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/xochilpili/subtitler-cli/internal/subtitle"
+)
+
+// newFlagSet returns a flag set whose -h prints the usage line and
+// description of the subcommand before its flags.
+func newFlagSet(name, usage, description string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "usage: subtitler %s\n\n%s\n", usage, description)
+		hasFlags := false
+		fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+		if hasFlags {
+			fmt.Fprintf(out, "\nflags:\n")
+			fs.PrintDefaults()
+		}
+	}
+	return fs
+}
+
+// UsageError is returned by the parsers for invalid flags or arguments,
+// unlike the errors reading the config file.
+type UsageError struct {
+	Err error
+	fs  *flag.FlagSet
+}
+
+func usageError(fs *flag.FlagSet, err error) *UsageError {
+	return &UsageError{Err: err, fs: fs}
+}
+
+func (e *UsageError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UsageError) Unwrap() error {
+	return e.Err
+}
+
+// Usage prints the usage of the subcommand the error belongs to.
+func (e *UsageError) Usage() {
+	e.fs.Usage()
+}
+
+// parseIds parses the subtitle ids given as positional arguments.
+func parseIds(args []string) ([]int, error) {
+	if len(args) < 1 {
+		return nil, errors.New("subtitle id is required")
+	}
+	ids := make([]int, len(args))
+	for i, arg := range args {
+		id, err := strconv.Atoi(arg)
+		if err != nil || id < 1 {
+			return nil, errors.New("invalid subtitle id " + arg)
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+// ParseDownloadFlags parses the flags of the download subcommand, the ids
+// to download are the positional arguments.
+func ParseDownloadFlags(args []string) (*OptionFlags, error) {
+	fs := newFlagSet("download", "download [flags] id...", "Download subtitles by id without searching, several ids are saved in a directory per id.")
+	debug := fs.Bool("d", false, "Debug mode")
+	downloadPath := fs.String("p", ".", "Download path")
+	provider := fs.String("provider", "subdivx", "Subtitle provider")
+	format := fs.String("format", "", "Convert downloaded subtitles to srt, ass, ssa, vtt or sub")
+	fps := fs.Float64("fps", subtitle.DefaultFPS, "Frame rate for frame based formats (sub)")
+	encoding := encodingFlag(fs)
+	output := fs.String("o", OutputTable, "Output format: table, json or ndjson")
+	keepArchive := fs.Bool("keep-archive", false, "Keep the downloaded archive next to the subtitles")
+	profile := profileFlag(fs)
+	noCache, cacheTTL := cacheFlags(fs)
+	rate, burst, concurrency := requestFlags(fs)
+
+	fs.Parse(args)
+	err := applyConfig(fs, *profile, configDefaults{
+		downloadPath: downloadPath,
+		provider:     provider,
+		output:       output,
+		concurrency:  concurrency,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ids, err := parseIds(fs.Args())
+	if err != nil {
+		return nil, usageError(fs, err)
+	}
+	if _, err := os.Stat(*downloadPath); os.IsNotExist(err) {
+		return nil, usageError(fs, errors.New("download path does not exist"))
+	}
+	if err := parseOutput(*output); err != nil {
+		return nil, usageError(fs, err)
+	}
+	selectedFormat, err := parseFormat(*format)
+	if err != nil {
+		return nil, usageError(fs, err)
+	}
+	selectedEncoding, err := parseEncoding(*encoding)
+	if err != nil {
+		return nil, usageError(fs, err)
+	}
+
+	dirname, _ := filepath.Abs(*downloadPath)
+
+	return &OptionFlags{
+		Ids:          ids,
+		Debug:        *debug,
+		DownloadPath: dirname,
+		Provider:     *provider,
+		Format:       selectedFormat,
+		FPS:          *fps,
+		Encoding:     selectedEncoding,
+		Output:       *output,
+		Comments:     CommentsNone,
+		KeepArchive:  *keepArchive,
+		NoCache:      *noCache,
+		CacheTTL:     *cacheTTL,
+		RateLimit:    *rate,
+		Burst:        *burst,
+		Concurrency:  *concurrency,
+	}, nil
+}
+
+// ParseCommentsFlags parses the flags of the comments subcommand, the ids
+// whose comments are shown are the positional arguments.
+func ParseCommentsFlags(args []string) (*OptionFlags, error) {
+	fs := newFlagSet("comments", "comments [flags] id...", "Show the comments of subtitles by id.")
+	var releases Releases
+	fs.Var(&releases, "r", "Releases")
+	debug := fs.Bool("d", false, "Debug mode")
+	style := fs.String("t", "dark", "table style")
+	provider := fs.String("provider", "subdivx", "Subtitle provider")
+	output := fs.String("o", OutputTable, "Output format: table, json or ndjson")
+	profile := profileFlag(fs)
+	noCache, cacheTTL := cacheFlags(fs)
+	rate, burst, concurrency := requestFlags(fs)
+
+	fs.Parse(args)
+	err := applyConfig(fs, *profile, configDefaults{
+		releases:    &releases,
+		style:       style,
+		provider:    provider,
+		output:      output,
+		concurrency: concurrency,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ids, err := parseIds(fs.Args())
+	if err != nil {
+		return nil, usageError(fs, err)
+	}
+	if err := parseOutput(*output); err != nil {
+		return nil, usageError(fs, err)
+	}
+
+	return &OptionFlags{
+		Ids:         ids,
+		Releases:    releases,
+		Debug:       *debug,
+		Style:       parseStyle(*style),
+		Provider:    *provider,
+		Output:      *output,
+		Comments:    CommentsLazy,
+		NoCache:     *noCache,
+		CacheTTL:    *cacheTTL,
+		RateLimit:   *rate,
+		Burst:       *burst,
+		Concurrency: *concurrency,
+	}, nil
+}
+
+type ConvertFlags struct {
+	Files  []string
+	Format subtitle.Format
+	Output string
+	FPS    float64
+}
+
+// ParseConvertFlags parses the flags of the convert subcommand, the
+// subtitles to convert are the positional arguments.
+func ParseConvertFlags(args []string) (*ConvertFlags, error) {
+	fs := newFlagSet("convert", "convert -to format [flags] file...", "Convert subtitle files to another format, the sources are kept.")
+	to := fs.String("to", "", "Target format: srt, ass, ssa, vtt or sub")
+	output := fs.String("o", "", "Output file, next to the input with the new extension by default")
+	fps := fs.Float64("fps", subtitle.DefaultFPS, "Frame rate for frame based formats (sub)")
+
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		return nil, usageError(fs, errors.New("subtitle file is required"))
+	}
+	if len(*to) < 1 {
+		return nil, usageError(fs, errors.New("target format is required"))
+	}
+	if len(*output) > 0 && fs.NArg() > 1 {
+		return nil, usageError(fs, errors.New("output can only be used with a single subtitle"))
+	}
+	format, err := parseFormat(*to)
+	if err != nil {
+		return nil, usageError(fs, err)
+	}
+
+	return &ConvertFlags{
+		Files:  fs.Args(),
+		Format: format,
+		Output: *output,
+		FPS:    *fps,
+	}, nil
+}
+
+// Config subcommand actions.
+const (
+	ConfigPath     = "path"
+	ConfigShow     = "show"
+	ConfigProfiles = "profiles"
+)
+
+type ConfigFlags struct {
+	Action  string
+	Profile string
+}
+
+// ParseConfigFlags parses the flags of the config subcommand, the action is
+// the first positional argument.
+func ParseConfigFlags(args []string) (*ConfigFlags, error) {
+	fs := newFlagSet("config", "config [flags] path|show|profiles", "Show where the config file is, its profiles or the settings a profile resolves to.")
+	profile := profileFlag(fs)
+
+	fs.Parse(args)
+	action := ConfigShow
+	if fs.NArg() > 0 {
+		action = fs.Arg(0)
+	}
+	if action != ConfigPath && action != ConfigShow && action != ConfigProfiles {
+		return nil, usageError(fs, errors.New("unknown config action "+action+", expected path, show or profiles"))
+	}
+	return &ConfigFlags{Action: action, Profile: *profile}, nil
+}
+
+// Cache subcommand actions.
+const (
+	CachePath  = "path"
+	CacheClear = "clear"
+)
+
+type CacheFlags struct {
+	Action string
+}
+
+// ParseCacheFlags parses the flags of the cache subcommand, the action is
+// the first positional argument.
+func ParseCacheFlags(args []string) (*CacheFlags, error) {
+	fs := newFlagSet("cache", "cache [path|clear]", "Show where the on-disk cache is or remove every cached search, comment and archive.")
+
+	fs.Parse(args)
+	action := CachePath
+	if fs.NArg() > 0 {
+		action = fs.Arg(0)
+	}
+	if action != CachePath && action != CacheClear {
+		return nil, usageError(fs, errors.New("unknown cache action "+action+", expected path or clear"))
+	}
+	return &CacheFlags{Action: action}, nil
+}
+
+func parseOutput(output string) error {
+	if output != OutputTable && output != OutputJSON && output != OutputNDJSON {
+		return errors.New("output must be table, json or ndjson")
+	}
+	return nil
+}
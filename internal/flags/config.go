@@ -0,0 +1,62 @@
+package flags
+
+import (
+	"flag"
+
+	"github.com/xochilpili/subtitler-cli/internal/config"
+)
+
+// configDefaults points at the flag values the config file can set, nil
+// when the command has no such flag.
+type configDefaults struct {
+	downloadPath *string
+	releases     *Releases
+	style        *string
+	provider     *string
+	output       *string
+	language     *string
+	concurrency  *int
+}
+
+// applyConfig fills the flags that weren't given on the command line from
+// the config file and the selected profile, so flags always win.
+func applyConfig(fs *flag.FlagSet, profile string, defaults configDefaults) error {
+	path, err := config.Path()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+	settings, err := cfg.Profile(profile)
+	if err != nil {
+		return err
+	}
+
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+	setString := func(name string, target *string, value string) {
+		if target != nil && value != "" && !given[name] {
+			*target = value
+		}
+	}
+	setString("p", defaults.downloadPath, config.ExpandHome(settings.DownloadPath))
+	setString("t", defaults.style, settings.Style)
+	setString("provider", defaults.provider, settings.Provider)
+	setString("o", defaults.output, settings.Output)
+	setString("lang", defaults.language, settings.Language)
+	if defaults.releases != nil && len(settings.Releases) > 0 && !given["r"] {
+		*defaults.releases = settings.Releases
+	}
+	if defaults.concurrency != nil && settings.Concurrency > 0 && !given["concurrency"] {
+		*defaults.concurrency = settings.Concurrency
+	}
+	return nil
+}
+
+func profileFlag(fs *flag.FlagSet) *string {
+	return fs.String("profile", "", "Config file profile to use")
+}
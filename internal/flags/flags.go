@@ -1,12 +1,19 @@
 package flags
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/xochilpili/subtitler-cli/internal/cache"
+	files "github.com/xochilpili/subtitler-cli/internal/files"
+	"github.com/xochilpili/subtitler-cli/internal/media"
+	"github.com/xochilpili/subtitler-cli/internal/scheduler"
+	"github.com/xochilpili/subtitler-cli/internal/subtitle"
 )
 
 type Releases []string
@@ -19,36 +26,385 @@ func (r *Releases) Set(s string) error {
 	return nil
 }
 
+// Words collects a repeatable flag, e.g. -match FLUX -match 1080p.
+type Words []string
+
+func (w *Words) String() string {
+	return fmt.Sprintln(*w)
+}
+func (w *Words) Set(s string) error {
+	*w = append(*w, s)
+	return nil
+}
+
+const (
+	OutputTable  = "table"
+	OutputJSON   = "json"
+	OutputNDJSON = "ndjson"
+)
+
+// Comments modes, eager fetches the comments of every result with the
+// search, lazy only when asked for in the menu and none never.
+const (
+	CommentsEager = "eager"
+	CommentsLazy  = "lazy"
+	CommentsNone  = "none"
+)
+
+// Sort keys of the search results.
+const (
+	SortRelevance = "relevance"
+	SortDownloads = "downloads"
+	SortDate      = "date"
+	SortCds       = "cds"
+)
+
+var Sorts = []string{SortRelevance, SortDownloads, SortDate, SortCds}
+
 type OptionFlags struct {
 	Title        string
+	Ids          []int
 	Releases     []string
 	Debug        bool
 	Style        table.Style
 	DownloadPath string
+	Provider     string
+	File         string
+	Release      *media.Release
+	Auto         bool
+	Language     string
+	Episode      *media.Episode
+	OnlyEpisode  bool
+	Format       subtitle.Format
+	FPS          float64
+	Output       string
+	Addr         string
+	NoCache      bool
+	CacheTTL     cache.TTL
+	RateLimit    float64
+	Burst        int
+	Concurrency  int
+	Comments     string
+	Stay         bool
+	TUI          bool
+	Sort         string
+	MinDownloads int
+	Cds          int
+	Match        []string
+	Exclude      []string
+	Limit        int
+	All          bool
+	KeepArchive  bool
+	Encoding     string
 }
 
-func ParseFlags() *OptionFlags {
-	// TODO: Add a download path
-	titleFlag := flag.String("s", "", "Subtitle title")
+// Interactive reports whether results are rendered for a person rather than
+// another program.
+func (o *OptionFlags) Interactive() bool {
+	return o.Output == OutputTable
+}
+
+// ParseSearchFlags parses the flags of the search subcommand, the default
+// one when no subcommand is given.
+func ParseSearchFlags(args []string) (*OptionFlags, error) {
+	fs := newFlagSet("search", "search [flags]", "Search subtitles by title or video file and pick the ones to download.")
+	titleFlag := fs.String("s", "", "Subtitle title")
+	fileFlag := fs.String("f", "", "Video file to search subtitles for")
 	var releases Releases
-	flag.Var(&releases, "r", "Releases")
-	debug := flag.Bool("d", false, "Debug mode")
-	style := flag.String("t", "dark", "table style")
-	downloadPath := flag.String("p", ".", "Download path")
+	fs.Var(&releases, "r", "Releases")
+	debug := fs.Bool("d", false, "Debug mode")
+	style := fs.String("t", "dark", "table style")
+	downloadPath := fs.String("p", ".", "Download path")
+	provider := fs.String("provider", "subdivx", "Subtitle provider")
+	auto := fs.Bool("auto", false, "Download the best match without prompting")
+	onlyEpisode := fs.Bool("episode", false, "Keep only subtitles of the searched episode")
+	format := fs.String("format", "", "Convert downloaded subtitles to srt, ass, ssa, vtt or sub")
+	fps := fs.Float64("fps", subtitle.DefaultFPS, "Frame rate for frame based formats (sub)")
+	encoding := encodingFlag(fs)
+	output := fs.String("o", OutputTable, "Output format: table, json or ndjson")
+	comments := fs.String("comments", CommentsEager, "When to fetch comments: eager, lazy or none")
+	stay := fs.Bool("stay", false, "Stay in the menu after downloading")
+	tui := fs.Bool("tui", false, "Browse results in a full screen terminal ui")
+	sortBy := fs.String("sort", "", "Sort results by relevance, downloads, date or cds")
+	minDownloads := fs.Int("min-downloads", 0, "Hide subtitles with fewer downloads")
+	cds := fs.Int("cds", 0, "Keep only subtitles with this number of CDs")
+	limit := fs.Int("limit", 0, "Fetch pages until this many results are found")
+	all := fs.Bool("all", false, "Fetch every page of results")
+	profile := profileFlag(fs)
+	var match, exclude Words
+	fs.Var(&match, "match", "Keep only subtitles mentioning this word (repeatable)")
+	fs.Var(&exclude, "exclude", "Hide subtitles mentioning this word (repeatable)")
+	noCache, cacheTTL := cacheFlags(fs)
+	rate, burst, concurrency := requestFlags(fs)
 
-	flag.Parse()
+	fs.Parse(args)
+	err := applyConfig(fs, *profile, configDefaults{
+		downloadPath: downloadPath,
+		releases:     &releases,
+		style:        style,
+		provider:     provider,
+		output:       output,
+		concurrency:  concurrency,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var release *media.Release
+	var episode *media.Episode
+	if len(*fileFlag) > 0 {
+		release = media.Parse(*fileFlag)
+		if len(*titleFlag) <= 0 {
+			*titleFlag = release.Query()
+		}
+		if release.IsEpisode() {
+			tag := release.EpisodeTag()
+			episode = &tag
+		}
+	}
 	if len(*titleFlag) <= 0 {
-		panic("title is required flag")
+		return nil, usageError(fs, errors.New("title or file is required flag"))
+	}
+	if tag, ok := media.ParseEpisode(*titleFlag); ok && episode == nil {
+		episode = &tag
 	}
 
 	if _, err := os.Stat(*downloadPath); os.IsNotExist(err) {
-		panic("download path does not exist")
+		return nil, usageError(fs, errors.New("download path does not exist"))
+	}
+	selectedFormat, err := parseFormat(*format)
+	if err != nil {
+		return nil, usageError(fs, err)
+	}
+	selectedEncoding, err := parseEncoding(*encoding)
+	if err != nil {
+		return nil, usageError(fs, err)
+	}
+	if err := parseOutput(*output); err != nil {
+		return nil, usageError(fs, err)
+	}
+	if *comments != CommentsEager && *comments != CommentsLazy && *comments != CommentsNone {
+		return nil, usageError(fs, errors.New("comments must be eager, lazy or none"))
+	}
+	if *limit < 0 {
+		return nil, usageError(fs, errors.New("limit must be positive"))
+	}
+	if *sortBy != "" && !IsSort(*sortBy) {
+		return nil, usageError(fs, errors.New("sort must be relevance, downloads, date or cds"))
 	}
 
 	dirname, _ := filepath.Abs(*downloadPath)
 
+	return &OptionFlags{
+		Title:        *titleFlag,
+		Releases:     releases,
+		Debug:        *debug,
+		Style:        parseStyle(*style),
+		DownloadPath: dirname,
+		Provider:     *provider,
+		File:         *fileFlag,
+		Release:      release,
+		Auto:         *auto,
+		Episode:      episode,
+		OnlyEpisode:  *onlyEpisode,
+		Format:       selectedFormat,
+		FPS:          *fps,
+		Encoding:     selectedEncoding,
+		Output:       *output,
+		Comments:     *comments,
+		Stay:         *stay,
+		TUI:          *tui,
+		Sort:         *sortBy,
+		MinDownloads: *minDownloads,
+		Cds:          *cds,
+		Match:        match,
+		Exclude:      exclude,
+		Limit:        *limit,
+		All:          *all,
+		NoCache:      *noCache,
+		CacheTTL:     *cacheTTL,
+		RateLimit:    *rate,
+		Burst:        *burst,
+		Concurrency:  *concurrency,
+	}, nil
+}
+
+// IsSort reports whether by is one of the sort keys.
+func IsSort(by string) bool {
+	for _, sort := range Sorts {
+		if by == sort {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseScanFlags parses the flags of the scan subcommand, the directory to
+// scan is the first positional argument and defaults to the current one.
+func ParseScanFlags(args []string) (*OptionFlags, error) {
+	fs := newFlagSet("scan", "scan [flags] [dir]", "Find videos without subtitles under dir and download the best match for each.")
+	var releases Releases
+	fs.Var(&releases, "r", "Releases")
+	debug := fs.Bool("d", false, "Debug mode")
+	style := fs.String("t", "dark", "table style")
+	provider := fs.String("provider", "subdivx", "Subtitle provider")
+	language := fs.String("lang", "es", "Language suffix of the renamed subtitles")
+	format := fs.String("format", "", "Convert downloaded subtitles to srt, ass, ssa, vtt or sub")
+	fps := fs.Float64("fps", subtitle.DefaultFPS, "Frame rate for frame based formats (sub)")
+	encoding := encodingFlag(fs)
+	noCache, cacheTTL := cacheFlags(fs)
+	rate, burst, concurrency := requestFlags(fs)
+	profile := profileFlag(fs)
+
+	fs.Parse(args)
+	err := applyConfig(fs, *profile, configDefaults{
+		releases:    &releases,
+		style:       style,
+		provider:    provider,
+		language:    language,
+		concurrency: concurrency,
+	})
+	if err != nil {
+		return nil, err
+	}
+	root := "."
+	if fs.NArg() > 0 {
+		root = fs.Arg(0)
+	}
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil, usageError(fs, errors.New("scan path does not exist"))
+	}
+	selectedFormat, err := parseFormat(*format)
+	if err != nil {
+		return nil, usageError(fs, err)
+	}
+	selectedEncoding, err := parseEncoding(*encoding)
+	if err != nil {
+		return nil, usageError(fs, err)
+	}
+
+	dirname, _ := filepath.Abs(root)
+
+	return &OptionFlags{
+		Releases:     releases,
+		Debug:        *debug,
+		Style:        parseStyle(*style),
+		DownloadPath: dirname,
+		Provider:     *provider,
+		Auto:         true,
+		Language:     *language,
+		Format:       selectedFormat,
+		FPS:          *fps,
+		Encoding:     selectedEncoding,
+		Output:       OutputTable,
+		NoCache:      *noCache,
+		CacheTTL:     *cacheTTL,
+		RateLimit:    *rate,
+		Burst:        *burst,
+		Concurrency:  *concurrency,
+	}, nil
+}
+
+// ParseServeFlags parses the flags of the serve subcommand.
+func ParseServeFlags(args []string) (*OptionFlags, error) {
+	fs := newFlagSet("serve", "serve [flags]", "Serve search, comments and downloads as a JSON http api.")
+	var releases Releases
+	fs.Var(&releases, "r", "Releases")
+	debug := fs.Bool("d", false, "Debug mode")
+	downloadPath := fs.String("p", ".", "Download path")
+	provider := fs.String("provider", "subdivx", "Subtitle provider")
+	format := fs.String("format", "", "Convert downloaded subtitles to srt, ass, ssa, vtt or sub")
+	fps := fs.Float64("fps", subtitle.DefaultFPS, "Frame rate for frame based formats (sub)")
+	encoding := encodingFlag(fs)
+	addr := fs.String("addr", "127.0.0.1:8080", "Address to listen on")
+	noCache, cacheTTL := cacheFlags(fs)
+	rate, burst, concurrency := requestFlags(fs)
+	profile := profileFlag(fs)
+
+	fs.Parse(args)
+	err := applyConfig(fs, *profile, configDefaults{
+		downloadPath: downloadPath,
+		releases:     &releases,
+		provider:     provider,
+		concurrency:  concurrency,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(*downloadPath); os.IsNotExist(err) {
+		return nil, usageError(fs, errors.New("download path does not exist"))
+	}
+	selectedFormat, err := parseFormat(*format)
+	if err != nil {
+		return nil, usageError(fs, err)
+	}
+	selectedEncoding, err := parseEncoding(*encoding)
+	if err != nil {
+		return nil, usageError(fs, err)
+	}
+
+	dirname, _ := filepath.Abs(*downloadPath)
+
+	return &OptionFlags{
+		Releases:     releases,
+		Debug:        *debug,
+		DownloadPath: dirname,
+		Provider:     *provider,
+		Format:       selectedFormat,
+		FPS:          *fps,
+		Encoding:     selectedEncoding,
+		Output:       OutputJSON,
+		Addr:         *addr,
+		NoCache:      *noCache,
+		CacheTTL:     *cacheTTL,
+		RateLimit:    *rate,
+		Burst:        *burst,
+		Concurrency:  *concurrency,
+	}, nil
+}
+
+// encodingFlag registers the flag overriding the detected encoding of the
+// downloaded subtitles.
+func encodingFlag(fs *flag.FlagSet) *string {
+	return fs.String("encoding", "", "Read subtitles in this encoding instead of detecting it, e.g. windows-1252")
+}
+
+func parseEncoding(encoding string) (string, error) {
+	if encoding != "" && !files.ValidEncoding(encoding) {
+		return "", errors.New("unknown encoding " + encoding)
+	}
+	return encoding, nil
+}
+
+// cacheFlags registers the cache flags shared by the commands that query a
+// provider.
+func cacheFlags(fs *flag.FlagSet) (*bool, *cache.TTL) {
+	ttl := cache.DefaultTTL
+	noCache := fs.Bool("no-cache", false, "Don't read or write the on-disk cache")
+	fs.DurationVar(&ttl.Search, "search-ttl", ttl.Search, "How long cached searches are used")
+	fs.DurationVar(&ttl.Comments, "comments-ttl", ttl.Comments, "How long cached comments are used")
+	fs.DurationVar(&ttl.Archives, "archive-ttl", ttl.Archives, "How long cached archives are used")
+	return noCache, &ttl
+}
+
+// requestFlags registers the flags of the request scheduler and the
+// comments worker pool.
+func requestFlags(fs *flag.FlagSet) (*float64, *int, *int) {
+	rate := fs.Float64("rate", scheduler.DefaultOptions.Rate, "Search and comment requests per second")
+	burst := fs.Int("burst", scheduler.DefaultOptions.Burst, "Requests allowed at once before rate limiting")
+	concurrency := fs.Int("concurrency", 4, "Comments fetched at the same time")
+	return rate, burst, concurrency
+}
+
+func parseFormat(format string) (subtitle.Format, error) {
+	if format == "" {
+		return "", nil
+	}
+	return subtitle.ParseFormat(format)
+}
+
+func parseStyle(style string) table.Style {
 	var selectedStyle table.Style
-	switch *style {
+	switch style {
 	case "dark":
 		selectedStyle = table.StyleColoredDark
 	case "light":
@@ -60,12 +416,74 @@ func ParseFlags() *OptionFlags {
 	case "red":
 		selectedStyle = table.StyleColoredBlackOnRedWhite
 	}
+	return selectedStyle
+}
 
-	return &OptionFlags{
-		Title:        *titleFlag,
-		Releases:     releases,
-		Debug:        *debug,
-		Style:        selectedStyle,
-		DownloadPath: dirname,
+type Anchors []subtitle.Anchor
+
+func (a *Anchors) String() string {
+	return fmt.Sprintln(*a)
+}
+func (a *Anchors) Set(s string) error {
+	anchor, err := subtitle.ParseAnchor(s)
+	if err != nil {
+		return err
+	}
+	*a = append(*a, anchor)
+	return nil
+}
+
+type SyncFlags struct {
+	Files   []string
+	Output  string
+	FPS     float64
+	Options subtitle.SyncOptions
+}
+
+// ParseSyncFlags parses the flags of the sync subcommand, the subtitles to
+// fix are the positional arguments.
+func ParseSyncFlags(args []string) (*SyncFlags, error) {
+	fs := newFlagSet("sync", "sync [flags] file...", "Shift, stretch or change the frame rate of subtitle files in place.")
+	offset := fs.String("offset", "", "Constant offset, e.g. +1.5s, -500ms or -00:00:01,500")
+	var anchors Anchors
+	fs.Var(&anchors, "anchor", "Stretch anchor from=to, e.g. 00:01:00,000=00:01:02,500 (given twice)")
+	fromFPS := fs.Float64("from-fps", 0, "Frame rate the subtitle was made for")
+	toFPS := fs.Float64("to-fps", 0, "Frame rate of the video")
+	output := fs.String("o", "", "Output file, the input is overwritten by default")
+	fps := fs.Float64("fps", subtitle.DefaultFPS, "Frame rate for frame based formats (sub)")
+
+	fs.Parse(args)
+	if fs.NArg() < 1 {
+		return nil, usageError(fs, errors.New("subtitle file is required"))
 	}
+	if len(*output) > 0 && fs.NArg() > 1 {
+		return nil, usageError(fs, errors.New("output can only be used with a single subtitle"))
+	}
+
+	var shift time.Duration
+	if len(*offset) > 0 {
+		var err error
+		shift, err = subtitle.ParseOffset(*offset)
+		if err != nil {
+			return nil, usageError(fs, err)
+		}
+	}
+	if len(anchors) != 0 && len(anchors) != 2 {
+		return nil, usageError(fs, errors.New("stretch needs exactly two anchors"))
+	}
+	if (*fromFPS > 0) != (*toFPS > 0) {
+		return nil, usageError(fs, errors.New("from-fps and to-fps must be given together"))
+	}
+
+	return &SyncFlags{
+		Files:  fs.Args(),
+		Output: *output,
+		FPS:    *fps,
+		Options: subtitle.SyncOptions{
+			Offset:  shift,
+			Anchors: anchors,
+			FromFPS: *fromFPS,
+			ToFPS:   *toFPS,
+		},
+	}, nil
 }
@@ -0,0 +1,89 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/xochilpili/subtitler-cli/internal/scheduler"
+)
+
+// ErrNotFound matches a StatusError for a missing page or subtitle.
+var ErrNotFound = errors.New("not found")
+
+// ErrForbidden matches a StatusError for a request the server refused, a
+// stale session or token usually.
+var ErrForbidden = errors.New("forbidden")
+
+// ErrThrottled matches the errors of a server asking to slow down, they are
+// scheduler.ThrottledError so the scheduler backs off and retries them.
+var ErrThrottled = scheduler.ErrThrottled
+
+// NetworkError is a request that didn't get a response: dns, connection,
+// timeout or redirect failures.
+type NetworkError struct {
+	URL string
+	Err error
+}
+
+func (e *NetworkError) Error() string {
+	return fmt.Sprintf("network error requesting %s: %v", e.URL, e.Err)
+}
+
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// StatusError is a response with an unexpected status code.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %s from %s", e.Status, e.URL)
+}
+
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrForbidden:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
+// DecodeError is a response body that couldn't be decoded.
+type DecodeError struct {
+	URL string
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("error decoding response from %s: %v", e.URL, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// CheckResponse returns the error for the status of res: a
+// scheduler.ThrottledError for 429 and 503, honouring Retry-After, and a
+// StatusError for any other status from 400 up.
+func CheckResponse(res *http.Response) error {
+	switch {
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode == http.StatusServiceUnavailable:
+		var retryAfter time.Duration
+		if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		return &scheduler.ThrottledError{Reason: res.Status, RetryAfter: retryAfter}
+	case res.StatusCode >= 400:
+		return &StatusError{URL: res.Request.URL.String(), StatusCode: res.StatusCode, Status: res.Status}
+	}
+	return nil
+}
@@ -7,180 +7,100 @@ import (
 	"io"
 	"net/http"
 	"net/http/httputil"
-	"os"
-	"path/filepath"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/xochilpili/subtitler-cli/internal/logger"
 )
 
+// HttpClient is the transport of the providers. Responses with an error
+// status come back as typed errors, otherwise the caller closes the body.
 type HttpClient interface {
-	Get(ctx context.Context, url string, target interface{}) (string, error)
-	Post(ctx context.Context, url string, body io.Reader, target interface{}, contentType string, cookie string) error
-	DownloadFile(ctx context.Context, url string, fileName string) (string, error)
+	Get(ctx context.Context, url string, headers map[string]string) (*http.Response, error)
+	PostForm(ctx context.Context, url string, form url.Values, headers map[string]string) (*http.Response, error)
 }
 
 type httpClient struct {
-	Timeout time.Duration
-	Debug   bool
+	client *http.Client
+	Debug  bool
 }
 
-func New(debug bool) *httpClient {
+// New returns a client whose cookies are kept in jar, nil keeps none.
+func New(debug bool, jar http.CookieJar) *httpClient {
 	return &httpClient{
-		Timeout: time.Duration(10 * time.Second),
-		Debug:   debug,
+		client: &http.Client{
+			Timeout: time.Duration(30 * time.Second),
+			Jar:     jar,
+		},
+		Debug: debug,
 	}
 }
 
-func (h *httpClient) Get(ctx context.Context, url string, target interface{}) (string, error) {
-	client := &http.Client{Timeout: h.Timeout}
+func (h *httpClient) Get(ctx context.Context, url string, headers map[string]string) (*http.Response, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		panic(fmt.Errorf("error while create a new request: %v", err))
-	}
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36")
-	req.Header.Add("X-Requested-With", "XMLHttpRequest")
-	if h.Debug {
-		logger.Debug("%v: %s", "Request to", url)
+		return nil, fmt.Errorf("error while create a new request: %v", err)
 	}
+	return h.do(req, headers)
+}
 
-	resp, err := client.Do(req)
-
+func (h *httpClient) PostForm(ctx context.Context, url string, form url.Values, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(form.Encode()))
 	if err != nil {
-		panic(fmt.Errorf("error while requesting data to %s, error: %v", url, err))
+		return nil, fmt.Errorf("error while create a new request: %v", err)
 	}
-	defer resp.Body.Close()
-
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=UTF-8")
 	if h.Debug {
-		respDump, _ := httputil.DumpResponse(resp, true)
-		logger.Debug("%v:\n%s", "RESPONSE", respDump)
-		logger.Debug("Got cookie: %s\n", resp.Header.Get("Set-Cookie"))
-	}
-
-	err = json.NewDecoder(resp.Body).Decode(&target)
-	if err != nil {
-		return "", err
+		logger.Debug("%v: %s", "payload", form.Encode())
 	}
-	cookie := resp.Header.Get("Set-Cookie")
-	return cookie, nil
+	return h.do(req, headers)
 }
 
-func (h *httpClient) Post(ctx context.Context, url string, body io.Reader, target interface{}, contentType string, cookie string) error {
-	client := &http.Client{Timeout: h.Timeout}
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, body)
-	if err != nil {
-		panic(fmt.Errorf("error while create a new request: %s", err))
-	}
-
-	if contentType != "" && contentType == "form" {
-		req.Header.Add("Content-type", "application/x-www-form-urlencoded; charset=UTF-8")
-	} else {
-		req.Header.Add("Content-type", "application/json")
+func (h *httpClient) do(req *http.Request, headers map[string]string) (*http.Response, error) {
+	for key, value := range headers {
+		req.Header.Set(key, value)
 	}
-
-	req.Header.Add("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36")
-	// subdivx dev, you're an idiot.
-	req.Header.Add("Cookie", cookie)
-
 	if h.Debug {
-		logger.Debug("%v: %s, payload: %+v", "Request to", url, body)
+		logger.Debug("%v: %s %s", "Request to", req.Method, req.URL)
 	}
 
-	resp, err := client.Do(req)
+	resp, err := h.client.Do(req)
 	if err != nil {
-		panic(fmt.Errorf("error requesting data to: %s, payload: %v, err: %w", url, body, err))
+		return nil, &NetworkError{URL: req.URL.String(), Err: err}
 	}
-	defer resp.Body.Close()
 	if h.Debug {
-		respDump, _ := httputil.DumpResponse(resp, true)
+		respDump, _ := httputil.DumpResponse(resp, !isBinary(resp))
 		logger.Debug("%v:\n%s", "RESPONSE", respDump)
 	}
-
-	error := json.NewDecoder(resp.Body).Decode(&target)
-	if error != nil {
-		return err
+	if err := CheckResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
 	}
-
-	return nil
+	return resp, nil
 }
 
-func (h *httpClient) DownloadFile(ctx context.Context, url string, filename string) (string, error) {
-	client := &http.Client{
-		Timeout: time.Duration(time.Second * 30),
-		CheckRedirect: func(r *http.Request, via []*http.Request) error {
-			r.URL.Opaque = r.URL.Path
-			return http.ErrUseLastResponse
-		},
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-
-	if err != nil {
-		panic(fmt.Errorf("unable to create request: %v", err))
-	}
-
-	req.Header.Add("Host", "subdivx.com")
-	req.Header.Add("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
-	req.Header.Add("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:109.0) Gecko/20100101 Firefox/110.0")
-	req.Header.Add("Accept-Language", "en-US,en;q=0.5")
-	req.Header.Add("Referer", "https://subdivx.com/descargar.php")
-	req.Header.Add("Cookie", "all required cookies")
-	req.Header.Add("Connection", "keep-alive")
-
-	if h.Debug {
-		logger.Debug("%v: %s", "Request to", url)
-	}
-
-	resp, err := client.Do(req)
+// isBinary reports whether the body of resp isn't worth dumping.
+func isBinary(resp *http.Response) bool {
+	contentType := resp.Header.Get("Content-Type")
+	return !strings.HasPrefix(contentType, "text/") && !strings.Contains(contentType, "json")
+}
 
-	if err != nil {
-		panic(fmt.Errorf("unable to request %s, error : %v", url, err))
+// DecodeJSON decodes the body of resp into target and closes it.
+func DecodeJSON(resp *http.Response, target interface{}) error {
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return &DecodeError{URL: resp.Request.URL.String(), Err: err}
 	}
+	return nil
+}
 
+// ReadBody reads the whole body of resp and closes it.
+func ReadBody(resp *http.Response) ([]byte, error) {
 	defer resp.Body.Close()
-
-	if resp.StatusCode >= 300 && resp.StatusCode <= 399 {
-		redirectUrl, err := resp.Location()
-		if h.Debug {
-			logger.Debug("%v: %s", "Redirected to", redirectUrl)
-		}
-
-		if err != nil {
-			panic(fmt.Errorf("error redirecting to %s, err: %v", redirectUrl, err))
-		}
-
-		extensionFile := filepath.Ext(redirectUrl.Path)
-		fileName := filename + extensionFile
-		file, err := os.Create(fileName)
-
-		if err != nil {
-			panic(fmt.Errorf("error creating file: %v", err))
-		}
-
-		defer file.Close()
-
-		req.URL = redirectUrl
-		resp, err := client.Do(req)
-		if err != nil {
-			panic(fmt.Errorf("error while sending redirect to %s, error: %v", redirectUrl, err))
-		}
-		defer resp.Body.Close()
-
-		if h.Debug {
-			logger.Debug("%s:\n %v", "REDIRECT REQUEST", req)
-		}
-
-		size, err := io.Copy(file, resp.Body)
-
-		if err != nil {
-			panic(fmt.Errorf("error while created downloaded file %v", err))
-		}
-
-		if h.Debug {
-			logger.Debug("%s: %d", "downloaded file", size)
-		}
-		return fileName, nil
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &NetworkError{URL: resp.Request.URL.String(), Err: err}
 	}
-	return "", nil
+	return body, nil
 }
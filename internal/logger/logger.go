@@ -2,6 +2,8 @@ package logger
 
 import (
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/fatih/color"
 )
@@ -12,15 +14,26 @@ type Logger interface {
 	Debug(format string, a ...interface{})
 }
 
+var output io.Writer = os.Stdout
+
+// SetOutput redirects the log messages and returns where they went before,
+// machine readable output modes send them to stderr so stdout only carries
+// the results.
+func SetOutput(w io.Writer) io.Writer {
+	previous := output
+	output = w
+	return previous
+}
+
 func Info(format string, label string, value string) {
-	fmt.Printf(format+"\n", color.CyanString(label), color.WhiteString(value))
+	fmt.Fprintf(output, format+"\n", color.CyanString(label), color.WhiteString(value))
 }
 
 func Error(format string, label string, value string) {
-	fmt.Printf(format+"\n", color.CyanString(label), color.RedString(value))
+	fmt.Fprintf(output, format+"\n", color.CyanString(label), color.RedString(value))
 }
 
 func Debug(format string, a ...interface{}) {
 	b := color.New(color.FgGreen, color.BgBlack)
-	b.Printf(format+"\n", a...)
+	b.Fprintf(output, format+"\n", a...)
 }
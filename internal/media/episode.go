@@ -0,0 +1,58 @@
+package media
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+var (
+	seasonRe      = regexp.MustCompile(`(?i)\bs(\d{1,2})\b`)
+	seasonWordRe  = regexp.MustCompile(`(?i)\b(?:temporada|season|temp\.?)\s*(\d{1,2})\b`)
+	seasonOrdinal = regexp.MustCompile(`(?i)\b(\d{1,2})\s*(?:ra|da|ta|ma|va|na|a|º|°|st|nd|rd|th)?\s+(?:temporada|season)\b`)
+)
+
+// Episode is a season/episode pair, Episode is 0 for a whole season pack.
+type Episode struct {
+	Season  int
+	Episode int
+}
+
+// ParseEpisode finds a S01E02, 1x02 or season only tag in text.
+func ParseEpisode(text string) (Episode, bool) {
+	for _, re := range []*regexp.Regexp{episodeRe, episodeAltRe} {
+		if match := re.FindStringSubmatch(text); match != nil {
+			season, _ := strconv.Atoi(match[1])
+			episode, _ := strconv.Atoi(match[2])
+			return Episode{Season: season, Episode: episode}, true
+		}
+	}
+	for _, re := range []*regexp.Regexp{seasonRe, seasonWordRe, seasonOrdinal} {
+		if match := re.FindStringSubmatch(text); match != nil {
+			season, _ := strconv.Atoi(match[1])
+			return Episode{Season: season}, true
+		}
+	}
+	return Episode{}, false
+}
+
+// IsPack reports whether e is a whole season rather than a single episode.
+func (e Episode) IsPack() bool {
+	return e.Episode == 0
+}
+
+// Matches reports whether other contains e, either the same episode or the
+// pack of its season.
+func (e Episode) Matches(other Episode) bool {
+	if e.Season != other.Season {
+		return false
+	}
+	return other.IsPack() || e.Episode == other.Episode
+}
+
+func (e Episode) String() string {
+	if e.IsPack() {
+		return fmt.Sprintf("S%02d", e.Season)
+	}
+	return fmt.Sprintf("S%02dE%02d", e.Season, e.Episode)
+}
@@ -0,0 +1,188 @@
+package media
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReleaseGroups is the built-in list of release groups used to highlight
+// and match subtitles against a release.
+const ReleaseGroups = `fgt|evo|yts|yts?\.mx|yifi|yify|MkvCage|NoMeRcY|STRiFE|SiGMA|LucidTV|CHD|sujaidr|SAPHiRE|LEGI0N|hd4u|rarbg|ViSiON|ETRG|JYK|iFT|anoXmous|MkvCage|Ganool|TGx|klaxxon|icebane|greenbud1969|flawl3ss|metcon|proper|ntb|cm8|tbs|sva|avs|mtb|ion10|sauron|phoenix|minx|mvgroup|amiable|sadece|gooz|lite|killers|tbs|PHOENiX|memento|done|ExKinoRay|acool|starz|convoy|playnow|RedBlade|ntg|cmrg|cm|2hd|fty|haggis|Joy|dimension|0tv|fxg|kat|artsubs|horizon|axxo|diamond|asteroids|rarbg|unit3d|afg|xlf|pulsar|bamboozle|ebp|trump|bulit|pahe|lol|tjhd|DeeJayAhmed|DeeJahAhmed|HEVC|anoxmous|galaxy|aoc|flux|roen|silence|CiNEFiLE|wrd|rico|huzzah|RiSEHD`
+
+// Release holds the information that can be read out of a video file name.
+type Release struct {
+	Title      string
+	Year       int
+	Season     int
+	Episode    int
+	Resolution string
+	Source     string
+	Group      string
+}
+
+var (
+	yearRe       = regexp.MustCompile(`\b(19\d{2}|20\d{2})\b`)
+	episodeRe    = regexp.MustCompile(`(?i)\bs(\d{1,2})[ .]?e(\d{1,3})\b`)
+	episodeAltRe = regexp.MustCompile(`(?i)\b(\d{1,2})x(\d{2,3})\b`)
+	resolutionRe = regexp.MustCompile(`(?i)\b(480p|576p|720p|1080[pi]|2160p|4k)\b`)
+	sourceRe     = regexp.MustCompile(`(?i)\b(web-?dl|web-?rip|web|blu-?ray|bdrip|brrip|bdremux|remux|hdtv|dvdrip|dvdscr|hdrip|hdcam|cam|telesync|hdts|ts)\b`)
+	groupRe      = regexp.MustCompile(`-([A-Za-z0-9]+)(?:\[[^\]]*\])?$`)
+	// notGroupRe are hyphenated source, codec and audio suffixes such as
+	// WEB-DL or x-265 that end a name without a release group
+	notGroupRe  = regexp.MustCompile(`(?i)^(dl|rip|ray|remux|264|265|hd|ma|dts|aac|ac3|avc)$`)
+	bracketRe   = regexp.MustCompile(`^\[([^\]]+)\]|\[([^\]]+)\]$`)
+	separatorRe = regexp.MustCompile(`[._\s]+`)
+)
+
+// Parse reads title, year, season/episode, resolution, source and release
+// group from a video file path such as Movie.2023.1080p.WEB-DL.x264-FLUX.mkv
+func Parse(path string) *Release {
+	name := filepath.Base(path)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+
+	release := &Release{}
+	if match := groupRe.FindStringSubmatch(name); match != nil && !notGroupRe.MatchString(match[1]) {
+		release.Group = match[1]
+	} else if match := bracketRe.FindStringSubmatch(name); match != nil {
+		release.Group = strings.Trim(match[1]+match[2], " ")
+	}
+	name = bracketRe.ReplaceAllString(name, "")
+	normalized := separatorRe.ReplaceAllString(name, " ")
+
+	// the title ends where the first release token starts
+	end := len(normalized)
+	cut := func(loc []int) {
+		if loc != nil && loc[0] > 0 && loc[0] < end {
+			end = loc[0]
+		}
+	}
+
+	if match := episodeRe.FindStringSubmatchIndex(normalized); match != nil {
+		release.Season, _ = strconv.Atoi(normalized[match[2]:match[3]])
+		release.Episode, _ = strconv.Atoi(normalized[match[4]:match[5]])
+		cut(match)
+	} else if match := episodeAltRe.FindStringSubmatchIndex(normalized); match != nil {
+		release.Season, _ = strconv.Atoi(normalized[match[2]:match[3]])
+		release.Episode, _ = strconv.Atoi(normalized[match[4]:match[5]])
+		cut(match)
+	} else if match := seasonRe.FindStringSubmatchIndex(normalized); match != nil {
+		release.Season, _ = strconv.Atoi(normalized[match[2]:match[3]])
+		cut(match)
+	}
+	resolution := resolutionRe.FindStringSubmatchIndex(normalized)
+	if resolution != nil {
+		release.Resolution = strings.ToLower(normalized[resolution[2]:resolution[3]])
+	}
+	source := sourceRe.FindStringSubmatchIndex(normalized)
+	if source != nil {
+		release.Source = normalizeSource(normalized[source[2]:source[3]])
+	}
+	// the release year is the last one before the first release token, the
+	// ones in front of it or at the very beginning are part of the title
+	// (e.g. 2001 A Space Odyssey or Blade Runner 2049 2017)
+	var year []int
+	for _, match := range yearRe.FindAllStringSubmatchIndex(normalized, -1) {
+		if match[0] == 0 || match[0] >= end || !plausibleYear(normalized[match[2]:match[3]]) {
+			continue
+		}
+		if (resolution == nil || match[0] < resolution[0]) && (source == nil || match[0] < source[0]) {
+			year = match
+		}
+	}
+	if year != nil {
+		release.Year, _ = strconv.Atoi(normalized[year[2]:year[3]])
+		cut(year)
+	}
+	cut(resolution)
+	cut(source)
+
+	release.Title = strings.Trim(normalized[:end], " -([")
+	return release
+}
+
+// IsEpisode reports whether the release belongs to a tv series.
+func (r *Release) IsEpisode() bool {
+	return r.Season > 0
+}
+
+// EpisodeTag returns the season/episode of the release.
+func (r *Release) EpisodeTag() Episode {
+	return Episode{Season: r.Season, Episode: r.Episode}
+}
+
+// Query returns the text to search for, the title followed by the episode
+// tag for tv series.
+func (r *Release) Query() string {
+	if r.IsEpisode() {
+		return r.Title + " " + r.EpisodeTag().String()
+	}
+	return r.Title
+}
+
+// Score returns how well the given text (a subtitle title or description)
+// matches the release, higher is better.
+func (r *Release) Score(text string) int {
+	text = strings.ToLower(text)
+	score := 0
+	if r.Group != "" && containsWord(text, strings.ToLower(r.Group)) {
+		score += 10
+	}
+	if r.Resolution != "" && containsWord(text, r.Resolution) {
+		score += 3
+	}
+	if r.Source != "" {
+		for _, match := range sourceRe.FindAllString(text, -1) {
+			if normalizeSource(match) == r.Source {
+				score += 3
+				break
+			}
+		}
+	}
+	if r.Year > 0 && containsWord(text, strconv.Itoa(r.Year)) {
+		score += 2
+	}
+	if r.IsEpisode() {
+		if episode, ok := ParseEpisode(text); ok {
+			switch {
+			case episode == r.EpisodeTag():
+				score += 5
+			case r.EpisodeTag().Matches(episode):
+				score += 2
+			default:
+				score -= 5
+			}
+		}
+	}
+	return score
+}
+
+// plausibleYear reports whether year could be a release year rather than a
+// number in the title such as 2049.
+func plausibleYear(year string) bool {
+	n, _ := strconv.Atoi(year)
+	return n <= time.Now().Year()+1
+}
+
+func normalizeSource(source string) string {
+	source = strings.ToLower(strings.ReplaceAll(source, "-", ""))
+	switch source {
+	case "webdl", "web":
+		return "webdl"
+	case "bluray", "bdremux", "remux":
+		return "bluray"
+	case "hdts", "telesync", "ts":
+		return "ts"
+	}
+	return source
+}
+
+func containsWord(text string, word string) bool {
+	re, err := regexp.Compile(`(?i)(^|[^a-z0-9])` + regexp.QuoteMeta(word) + `($|[^a-z0-9])`)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(text)
+}
@@ -0,0 +1,116 @@
+package media
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		path string
+		want Release
+	}{
+		{
+			path: "Movie.2023.1080p.WEB-DL.x264-FLUX.mkv",
+			want: Release{Title: "Movie", Year: 2023, Resolution: "1080p", Source: "webdl", Group: "FLUX"},
+		},
+		{
+			path: "Movie.2023.1080p.WEB-DL.mkv",
+			want: Release{Title: "Movie", Year: 2023, Resolution: "1080p", Source: "webdl"},
+		},
+		{
+			path: "Movie.2023.720p.BluRay.x264-265.mkv",
+			want: Release{Title: "Movie", Year: 2023, Resolution: "720p", Source: "bluray"},
+		},
+		{
+			path: "Movie.2023.DVD-Rip.avi",
+			want: Release{Title: "Movie", Year: 2023},
+		},
+		{
+			path: "/videos/Blade.Runner.2049.2017.mkv",
+			want: Release{Title: "Blade Runner 2049", Year: 2017},
+		},
+		{
+			path: "Blade.Runner.2049.2017.2160p.BluRay-SiGMA.mkv",
+			want: Release{Title: "Blade Runner 2049", Year: 2017, Resolution: "2160p", Source: "bluray", Group: "SiGMA"},
+		},
+		{
+			path: "Blade.Runner.2049.1080p.mkv",
+			want: Release{Title: "Blade Runner 2049", Resolution: "1080p"},
+		},
+		{
+			path: "2001.A.Space.Odyssey.1968.mkv",
+			want: Release{Title: "2001 A Space Odyssey", Year: 1968},
+		},
+		{
+			path: "Show.Name.S01E02.720p.HDTV.x264-KILLERS.mkv",
+			want: Release{Title: "Show Name", Season: 1, Episode: 2, Resolution: "720p", Source: "hdtv", Group: "KILLERS"},
+		},
+		{
+			path: "Show.Name.2019.S03E10.1080p.mkv",
+			want: Release{Title: "Show Name", Year: 2019, Season: 3, Episode: 10, Resolution: "1080p"},
+		},
+		{
+			path: "Show Name 2x05.mp4",
+			want: Release{Title: "Show Name", Season: 2, Episode: 5},
+		},
+		{
+			path: "[Group] Show Name S02.mkv",
+			want: Release{Title: "Show Name", Season: 2, Group: "Group"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := Parse(tt.path); *got != tt.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.path, *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseEpisode(t *testing.T) {
+	tests := []struct {
+		text string
+		want Episode
+		ok   bool
+	}{
+		{"Show.S01E02.720p", Episode{Season: 1, Episode: 2}, true},
+		{"show s1e2", Episode{Season: 1, Episode: 2}, true},
+		{"Show S01 E02", Episode{Season: 1, Episode: 2}, true},
+		{"Show 3x07", Episode{Season: 3, Episode: 7}, true},
+		{"Show.S04.Complete", Episode{Season: 4}, true},
+		{"Show temporada 2", Episode{Season: 2}, true},
+		{"Show Season 5", Episode{Season: 5}, true},
+		{"Show 3ra temporada", Episode{Season: 3}, true},
+		{"Movie 2023 1080p", Episode{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, ok := ParseEpisode(tt.text)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("ParseEpisode(%q) = %v, %v, want %v, %v", tt.text, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		text string
+		want int
+	}{
+		{"group", "Movie.2023.1080p.WEB-DL.x264-FLUX.mkv", "Movie 2023 1080p WEB-DL FLUX", 18},
+		{"no group from source", "Movie.2023.1080p.WEB-DL.mkv", "Movie 2023 WEB-DL", 5},
+		{"source spellings", "Movie.2023.WEBDL.mkv", "movie web-dl", 3},
+		{"no match", "Movie.2023.1080p.BluRay-SiGMA.mkv", "Movie 720p HDTV", 0},
+		{"same episode", "Show.S01E02.720p.mkv", "Show S01E02 720p", 8},
+		{"season pack", "Show.S01E02.mkv", "Show temporada 1 completa", 2},
+		{"other episode", "Show.S01E02.mkv", "Show S01E03", -5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.path).Score(tt.text); got != tt.want {
+				t.Errorf("Parse(%q).Score(%q) = %d, want %d", tt.path, tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package menu
+
+import (
+	"errors"
+
+	files "github.com/xochilpili/subtitler-cli/internal/files"
+	httpclient "github.com/xochilpili/subtitler-cli/internal/http-client"
+	"github.com/xochilpili/subtitler-cli/internal/logger"
+)
+
+// describe adds to err what the user can do about it.
+func describe(err error) string {
+	var network *httpclient.NetworkError
+	var status *httpclient.StatusError
+	var decode *httpclient.DecodeError
+	switch {
+	case errors.Is(err, httpclient.ErrThrottled):
+		return err.Error() + ", wait a few seconds and retry"
+	case errors.Is(err, httpclient.ErrNotFound):
+		return err.Error() + ", check the subtitle still exists"
+	case errors.As(err, &network):
+		return err.Error() + ", check your connection and retry"
+	case errors.As(err, &status), errors.As(err, &decode):
+		return err.Error() + ", the site may be down, retry later"
+	case errors.Is(err, files.ErrUnsafeEntry), errors.Is(err, files.ErrTooLarge), errors.Is(err, files.ErrUnknownArchive):
+		return err.Error() + ", pick another subtitle"
+	}
+	return err.Error()
+}
+
+// report logs err without leaving the menu.
+func report(err error) {
+	logger.Error("%v %v", "error:", describe(err))
+}
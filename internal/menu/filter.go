@@ -0,0 +1,44 @@
+package menu
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/xochilpili/subtitler-cli/internal/service"
+)
+
+// parseFilter parses the arguments of the filter command, e.g.
+// "flux -cam cds=1 downloads=100". Words are required, the ones starting
+// with - are excluded. No arguments clears the filter.
+func parseFilter(args []string) (service.Filter, error) {
+	var filter service.Filter
+	for _, arg := range args {
+		if arg == "" {
+			continue
+		}
+		if key, value, ok := strings.Cut(arg, "="); ok {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return filter, fmt.Errorf("invalid number in %s", arg)
+			}
+			switch key {
+			case "cds":
+				filter.Cds = n
+			case "downloads":
+				filter.MinDownloads = n
+			default:
+				return filter, fmt.Errorf("unknown filter %s, expected cds or downloads", key)
+			}
+			continue
+		}
+		if word, ok := strings.CutPrefix(arg, "-"); ok {
+			if word != "" {
+				filter.Exclude = append(filter.Exclude, word)
+			}
+			continue
+		}
+		filter.Match = append(filter.Match, arg)
+	}
+	return filter, nil
+}
@@ -0,0 +1,36 @@
+package menu
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/xochilpili/subtitler-cli/internal/service"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    service.Filter
+		wantErr bool
+	}{
+		{name: "clear", args: nil, want: service.Filter{}},
+		{name: "words", args: []string{"flux", "", "-cam", "1080p"}, want: service.Filter{Match: []string{"flux", "1080p"}, Exclude: []string{"cam"}}},
+		{name: "numbers", args: []string{"cds=1", "downloads=100"}, want: service.Filter{Cds: 1, MinDownloads: 100}},
+		{name: "lone dash", args: []string{"-"}, want: service.Filter{}},
+		{name: "negative", args: []string{"cds=-1"}, wantErr: true},
+		{name: "not a number", args: []string{"downloads=many"}, wantErr: true},
+		{name: "unknown key", args: []string{"year=2020"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseFilter(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseFilter(%q) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseFilter(%q) = %+v, want %+v", tt.args, got, tt.want)
+			}
+		})
+	}
+}
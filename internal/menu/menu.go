@@ -3,58 +3,251 @@ package menu
 import (
 	"bufio"
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/jedib0t/go-pretty/v6/progress"
+	files "github.com/xochilpili/subtitler-cli/internal/files"
 	"github.com/xochilpili/subtitler-cli/internal/flags"
 	"github.com/xochilpili/subtitler-cli/internal/logger"
 	"github.com/xochilpili/subtitler-cli/internal/service"
+	"github.com/xochilpili/subtitler-cli/internal/tui"
+	"github.com/xochilpili/subtitler-cli/internal/worker"
 )
 
-type Subdivx interface {
-	FormatSubtitles(subtitles []service.Subtitles)
-	GetSubtitles(ctx context.Context) ([]service.Subtitles, error)
-	DownloadSubtitle(ctx context.Context, subtitleId int) error
-}
-
 type Menu struct {
 	settings  *flags.OptionFlags
-	service   Subdivx
+	service   service.Provider
+	formatter service.Formatter
+	filter    service.Filter
+	// results keeps every subtitle found in the current order, subtitles
+	// the ones shown after filtering.
+	results   []service.Subtitles
 	subtitles []service.Subtitles
+	// page is the page shown, nil when every result was fetched at once.
+	page *service.Page
 }
 
-func New(ctx context.Context, settings *flags.OptionFlags) *Menu {
-	service := service.NewSub(settings)
-	subtitles, _ := service.GetSubtitles(ctx)
+func New(settings *flags.OptionFlags) (*Menu, error) {
+	provider, err := service.NewProvider(settings.Provider, settings)
+	if err != nil {
+		return nil, err
+	}
 	return &Menu{
 		settings:  settings,
-		service:   service,
-		subtitles: subtitles,
+		service:   provider,
+		formatter: service.NewFormatter(settings),
+		filter:    service.NewFilter(settings),
+	}, nil
+}
+
+// Search runs the search of the settings, on error the menu is left empty
+// and the search can be retried from the prompt.
+func (m *Menu) Search(ctx context.Context) error {
+	// -limit and -all gather several pages at once, otherwise the menu moves
+	// page by page
+	if m.settings.All || m.settings.Limit > 0 {
+		// the pages fetched before an error are still shown
+		subtitles, err := m.service.GetSubtitles(ctx, m.settings.Title)
+		if err != nil && len(subtitles) == 0 {
+			return err
+		}
+		if loadErr := m.load(subtitles); loadErr != nil {
+			return loadErr
+		}
+		return err
+	}
+	page, err := m.service.SearchPage(ctx, m.settings.Title, 1)
+	if err != nil {
+		return err
 	}
+	m.page = page
+	return m.load(page.Subtitles)
+}
+
+// load replaces the results, keeping the episode, sort and filter settings.
+func (m *Menu) load(subtitles []service.Subtitles) error {
+	if m.settings.OnlyEpisode && m.settings.Episode != nil {
+		subtitles = service.FilterEpisode(*m.settings.Episode, subtitles)
+	}
+	service.RankSubtitles(m.settings.Release, subtitles)
+	if m.settings.Sort != "" {
+		if err := service.SortSubtitles(m.settings.Sort, m.settings, subtitles); err != nil {
+			return err
+		}
+	}
+	m.results = subtitles
+	m.apply()
+	return nil
+}
+
+// turnPage loads the page delta pages away from the current one.
+func (m *Menu) turnPage(delta int) error {
+	if m.page == nil {
+		return errors.New("paging is not available with -limit or -all")
+	}
+	number := m.page.Number + delta
+	if number < 1 || number > m.page.Pages() {
+		return fmt.Errorf("no more pages, showing page %d of %d", m.page.Number, m.page.Pages())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	page, err := m.service.SearchPage(ctx, m.settings.Title, number)
+	if err != nil {
+		return err
+	}
+	m.page = page
+	return m.load(page.Subtitles)
+}
+
+// apply refreshes the shown subtitles from the results and the filter.
+func (m *Menu) apply() {
+	m.subtitles = m.filter.Apply(m.results)
 }
 
 func (m *Menu) menu() {
-	m.service.FormatSubtitles(m.subtitles)
+	m.formatter.FormatSubtitles(m.subtitles)
+	if m.page != nil && m.settings.Interactive() && m.page.Pages() > 1 {
+		logger.Info("%v: %v", "Page", fmt.Sprintf("%d of %d (%d results), n next, p previous", m.page.Number, m.page.Pages(), m.page.Total))
+	}
+}
+
+// Print renders the search results once without prompting.
+func (m *Menu) Print() {
+	m.menu()
 }
 
 func (m *Menu) Start() {
 	m.start(os.Stdin)
 }
 
+// StartTUI browses the results in the full screen terminal ui.
+func (m *Menu) StartTUI() error {
+	return tui.New(m.settings, m.service, m.subtitles).Run()
+}
+
+// Auto downloads the highest scoring subtitle without prompting.
+func (m *Menu) Auto() error {
+	best := service.NewScorer(m.settings).Best(m.subtitles)
+	if best < 0 {
+		return errors.New("no subtitles found")
+	}
+	selected := m.subtitles[best]
+	logger.Info("%v:%v", "Selected option", selected.Title)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return m.download(ctx, selected)
+}
+
+func (m *Menu) download(ctx context.Context, selected service.Subtitles) error {
+	files, err := m.service.DownloadSubtitle(ctx, selected.Id, m.settings.DownloadPath)
+	if err != nil {
+		return err
+	}
+	m.formatter.FormatDownloadedFiles([]service.Download{service.NewDownload(selected.Id, files)})
+	return nil
+}
+
+// downloadMany downloads the selected subtitles concurrently, each one into
+// its own directory named by id so equally named files don't overwrite
+// each other. It reports whether every download succeeded, Ctrl-C cancels
+// the downloads left and goes back to the menu.
+func (m *Menu) downloadMany(indexes []int) bool {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	pw := progress.NewWriter()
+	pw.SetAutoStop(true)
+	pw.SetMessageLength(60)
+	pw.SetTrackerPosition(progress.PositionRight)
+	pw.ShowValue(false)
+	pw.SetNumTrackersExpected(len(indexes))
+
+	trackers := make([]*progress.Tracker, len(indexes))
+	for i, index := range indexes {
+		trackers[i] = &progress.Tracker{
+			Message: fmt.Sprintf("%d %s", m.subtitles[index].Id, m.subtitles[index].Title),
+			Total:   1,
+		}
+		pw.AppendTracker(trackers[i])
+	}
+	// render once every tracker is in, an early tick with none running
+	// would stop it
+	rendered := make(chan struct{})
+	go func() {
+		pw.Render()
+		close(rendered)
+	}()
+
+	type download struct {
+		tracker  *progress.Tracker
+		subtitle service.Subtitles
+	}
+	items := make([]download, len(indexes))
+	for i, index := range indexes {
+		items[i] = download{tracker: trackers[i], subtitle: m.subtitles[index]}
+	}
+	files, errs := worker.Map(ctx, m.settings.Concurrency, items, func(ctx context.Context, item download) ([]files.Result, error) {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		path := filepath.Join(m.settings.DownloadPath, strconv.Itoa(item.subtitle.Id))
+		if err := os.MkdirAll(path, 0755); err != nil {
+			item.tracker.MarkAsErrored()
+			return nil, err
+		}
+		files, err := m.service.DownloadSubtitle(ctx, item.subtitle.Id, path)
+		if err != nil {
+			item.tracker.MarkAsErrored()
+			return nil, err
+		}
+		item.tracker.MarkAsDone()
+		return files, nil
+	})
+	// the downloads skipped after Ctrl-C never ran, end their trackers so
+	// the progress stops
+	for _, tracker := range trackers {
+		if !tracker.IsDone() {
+			tracker.MarkAsErrored()
+		}
+	}
+	<-rendered
+
+	ok := true
+	var downloads []service.Download
+	for i, item := range items {
+		if errs[i] != nil {
+			logger.Error("%v %v", fmt.Sprintf("error downloading %d:", item.subtitle.Id), describe(errs[i]))
+			ok = false
+			continue
+		}
+		downloads = append(downloads, service.NewDownload(item.subtitle.Id, files[i]))
+	}
+	m.formatter.FormatDownloadedFiles(downloads)
+	return ok
+}
+
 func (m *Menu) start(reader io.Reader) {
 	first := false
+	input := bufio.NewReader(reader)
+	help := "(1 | 1,4,7 | 2-5 | all, q exit, m Subs, r Search again, n/p Page, sort <key>, filter <words>)"
+	if m.settings.Comments == flags.CommentsLazy {
+		help = "(1 | 1,4,7 | 2-5 | all, q exit, m Subs, r Search again, n/p Page, c <index> Comments, sort <key>, filter <words>)"
+	}
 MainLoop:
 	for {
-		input := bufio.NewReader(reader)
 		if !first {
 			m.menu()
 			first = true
 		}
 
-		logger.Info("%s, %s:", "Select a subtitle by index", "(q exit, m Subs)")
+		logger.Info("%s, %s:", "Select subtitles by index", help)
 		inputString, err := input.ReadString('\n')
 		if err != nil {
 			break MainLoop
@@ -71,24 +264,122 @@ MainLoop:
 			break MainLoop
 		case "menu", "m":
 			m.menu()
+		case "retry", "r":
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			err := m.Search(ctx)
+			cancel()
+			if err != nil {
+				report(err)
+				break Route
+			}
+			m.menu()
+		case "next", "n", "prev", "p":
+			delta := 1
+			if cmd[0] == "prev" || cmd[0] == "p" {
+				delta = -1
+			}
+			if err := m.turnPage(delta); err != nil {
+				report(err)
+				break Route
+			}
+			m.menu()
+		case "comments", "c":
+			if len(cmd) < 2 {
+				logger.Error("%v %v", "error:", "missing subtitle index, e.g. c 3")
+				break Route
+			}
+			index, err := m.index(cmd[1])
+			if err != nil {
+				logger.Error("%v %v", "error:", err.Error())
+				break Route
+			}
+			if err := m.comments(index); err != nil {
+				report(err)
+			}
+		case "sort":
+			if len(cmd) < 2 {
+				logger.Error("%v %v", "error:", "missing sort key, one of "+strings.Join(flags.Sorts, ", "))
+				break Route
+			}
+			if err := service.SortSubtitles(cmd[1], m.settings, m.results); err != nil {
+				logger.Error("%v %v", "error:", err.Error())
+				break Route
+			}
+			// keep the order on the next pages
+			m.settings.Sort = cmd[1]
+			m.apply()
+			m.menu()
+		case "filter":
+			filter, err := parseFilter(cmd[1:])
+			if err != nil {
+				logger.Error("%v %v", "error:", err.Error())
+				break Route
+			}
+			m.filter = filter
+			m.apply()
+			if filter.String() != "" {
+				logger.Info("%v: %v", "Filter", filter.String())
+			}
+			m.menu()
 		default:
-			if _, err := strconv.Atoi(cmd[0]); err != nil {
-				logger.Error("%v", "error:", "unrecognized option")
+			indexes, err := parseSelection(strings.Join(cmd, ""), len(m.subtitles))
+			if err != nil {
+				logger.Error("%v %v", "error:", err.Error())
 				break Route
 			}
-			index, _ := strconv.Atoi(cmd[0])
-			if index > len(m.subtitles)-1 {
-				logger.Error("%v", "error:", "invalid subtitle number")
+			if len(indexes) == 1 {
+				selected := m.subtitles[indexes[0]]
+				logger.Info("%v:%v", "Selected option", selected.Title)
+				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				err = m.download(ctx, selected)
+				cancel()
+				if err != nil {
+					// stay in the menu so the download can be retried
+					report(err)
+					break Route
+				}
+			} else if !m.downloadMany(indexes) {
 				break Route
 			}
-			selected := m.subtitles[index : index+1][0]
-			logger.Info("%v:%v", "Selected option", selected.Title)
-			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-			defer cancel()
-			m.service.DownloadSubtitle(ctx, selected.Id)
-			break MainLoop
+			if !m.settings.Stay {
+				break MainLoop
+			}
+		}
+	}
+}
+
+// comments shows the comments of a single subtitle, fetching them the first
+// time they are asked for.
+func (m *Menu) comments(index int) error {
+	if m.settings.Comments == flags.CommentsNone {
+		return errors.New("comments are disabled")
+	}
+	selected := &m.subtitles[index]
+	if selected.Comments == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		comments, err := m.service.GetComments(ctx, selected.Id)
+		if err != nil {
+			return err
 		}
+		selected.Comments = &comments
+		// keep them when the filter or the sort changes
+		for i := range m.results {
+			if m.results[i].Id == selected.Id {
+				m.results[i].Comments = &comments
+			}
+		}
+	}
+	m.formatter.FormatComments([]service.Subtitles{*selected})
+	return nil
+}
+
+func (m *Menu) index(arg string) (int, error) {
+	index, err := strconv.Atoi(arg)
+	if err != nil || index < 0 || index > len(m.subtitles)-1 {
+		return 0, errors.New("invalid subtitle number")
 	}
+	return index, nil
 }
 
 func cleanCommand(cmd string) ([]string, error) {
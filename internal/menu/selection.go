@@ -0,0 +1,62 @@
+package menu
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseSelection reads the subtitles picked in the menu: a single index
+// (3), a list (1,4,7), a range (2-5), a mix of them (1,3-5) or all. The
+// indexes are returned once each, in the order they were given.
+func parseSelection(input string, total int) ([]int, error) {
+	input = strings.ReplaceAll(strings.TrimSpace(input), " ", "")
+	if input == "" {
+		return nil, fmt.Errorf("empty selection")
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("no subtitles to select")
+	}
+	if strings.EqualFold(input, "all") || input == "*" {
+		indexes := make([]int, total)
+		for i := range indexes {
+			indexes[i] = i
+		}
+		return indexes, nil
+	}
+
+	var indexes []int
+	seen := map[int]bool{}
+	add := func(index int) error {
+		if index < 0 || index > total-1 {
+			return fmt.Errorf("invalid subtitle number %d", index)
+		}
+		if !seen[index] {
+			seen[index] = true
+			indexes = append(indexes, index)
+		}
+		return nil
+	}
+	for _, part := range strings.Split(input, ",") {
+		if part == "" {
+			continue
+		}
+		from, to, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selection %q", part)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(to); err != nil || end < start {
+				return nil, fmt.Errorf("invalid range %q", part)
+			}
+		}
+		for i := start; i <= end; i++ {
+			if err := add(i); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return indexes, nil
+}
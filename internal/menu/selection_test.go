@@ -0,0 +1,39 @@
+package menu
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSelection(t *testing.T) {
+	tests := []struct {
+		input   string
+		total   int
+		want    []int
+		wantErr bool
+	}{
+		{input: "3", total: 5, want: []int{3}},
+		{input: " 1, 4 ,0 ", total: 5, want: []int{1, 4, 0}},
+		{input: "2-4", total: 5, want: []int{2, 3, 4}},
+		{input: "4,1-2,2", total: 5, want: []int{4, 1, 2}},
+		{input: "1,,3", total: 5, want: []int{1, 3}},
+		{input: "all", total: 3, want: []int{0, 1, 2}},
+		{input: "ALL", total: 2, want: []int{0, 1}},
+		{input: "*", total: 1, want: []int{0}},
+		{input: "all", total: 0, wantErr: true},
+		{input: "0", total: 0, wantErr: true},
+		{input: "", total: 5, wantErr: true},
+		{input: "5", total: 5, wantErr: true},
+		{input: "-1", total: 5, wantErr: true},
+		{input: "3-1", total: 5, wantErr: true},
+		{input: "1-x", total: 5, wantErr: true},
+		{input: "3-9", total: 5, wantErr: true},
+		{input: "a", total: 5, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseSelection(tt.input, tt.total)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSelection(%q, %d) = %v, %v, want %v", tt.input, tt.total, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/xochilpili/subtitler-cli/internal/flags"
+	"github.com/xochilpili/subtitler-cli/internal/logger"
+	"github.com/xochilpili/subtitler-cli/internal/media"
+	"github.com/xochilpili/subtitler-cli/internal/service"
+)
+
+var videoExtensions = map[string]bool{
+	".mkv": true, ".mp4": true, ".avi": true, ".m4v": true, ".mov": true,
+	".wmv": true, ".mpg": true, ".mpeg": true, ".webm": true, ".flv": true,
+}
+
+var subtitleExtensions = []string{".srt", ".ssa", ".ass", ".vtt", ".sub"}
+
+type Result struct {
+	Video    string
+	Subtitle string
+	Err      error
+}
+
+type Scanner struct {
+	settings *flags.OptionFlags
+	provider service.Provider
+}
+
+// New returns a scanner that searches every video with the same provider,
+// so the provider session is shared across the whole batch.
+func New(settings *flags.OptionFlags, provider service.Provider) *Scanner {
+	return &Scanner{
+		settings: settings,
+		provider: provider,
+	}
+}
+
+// Videos walks root and returns the video files without a sibling subtitle.
+func (s *Scanner) Videos(root string) ([]string, error) {
+	var videos []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !videoExtensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		if !hasSubtitle(path) {
+			videos = append(videos, path)
+		}
+		return nil
+	})
+	return videos, err
+}
+
+// Run searches and downloads the best subtitle for every video missing one.
+func (s *Scanner) Run(ctx context.Context) error {
+	videos, err := s.Videos(s.settings.DownloadPath)
+	if err != nil {
+		return err
+	}
+	logger.Info("%v: %v", "videos without subtitles", fmt.Sprint(len(videos)))
+
+	var results []Result
+	failed := 0
+	for _, video := range videos {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		subtitle, err := s.fetch(ctx, video)
+		if err != nil {
+			failed++
+			logger.Error("%v %v", filepath.Base(video)+":", err.Error())
+		}
+		results = append(results, Result{Video: video, Subtitle: subtitle, Err: err})
+	}
+	s.formatResults(results)
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d videos failed", failed, len(videos))
+	}
+	return nil
+}
+
+func (s *Scanner) fetch(ctx context.Context, video string) (string, error) {
+	release := media.Parse(video)
+	logger.Info("%v: %v", "searching", release.Query())
+
+	subtitles, err := s.provider.GetSubtitles(ctx, release.Query())
+	if err != nil {
+		return "", err
+	}
+	if release.IsEpisode() {
+		subtitles = service.FilterEpisode(release.EpisodeTag(), subtitles)
+	}
+	settings := *s.settings
+	settings.Release = release
+	service.RankSubtitles(release, subtitles)
+	best := service.NewScorer(&settings).Best(subtitles)
+	if best < 0 {
+		return "", errors.New("no subtitles found")
+	}
+
+	// extract into a hidden directory next to the video, so only the
+	// selected subtitle ends up in the library
+	tmp, err := os.MkdirTemp(filepath.Dir(video), ".subtitler-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	downloadCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	files, err := s.provider.DownloadSubtitle(downloadCtx, subtitles[best].Id, tmp)
+	if err != nil {
+		return "", err
+	}
+	if len(files) < 1 {
+		return "", errors.New("downloaded archive has no subtitles")
+	}
+
+	selected := files[0].Path
+	bestScore := -1
+	for _, file := range files {
+		if score := release.Score(filepath.Base(file.Path)); score > bestScore {
+			selected = file.Path
+			bestScore = score
+		}
+	}
+
+	target := SubtitleName(video, s.settings.Language, filepath.Ext(selected))
+	if err := os.Rename(selected, target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// SubtitleName returns the path a subtitle for video is stored at,
+// e.g. Movie.mkv -> Movie.es.srt
+func SubtitleName(video string, language string, ext string) string {
+	base := strings.TrimSuffix(video, filepath.Ext(video))
+	if language != "" {
+		base += "." + language
+	}
+	return base + strings.ToLower(ext)
+}
+
+// hasSubtitle reports whether video already has a subtitle next to it named
+// like SubtitleName does, with or without a single language segment, so
+// Movie.es.srt counts for Movie.mkv but Movie.Directors.Cut.es.srt doesn't.
+func hasSubtitle(video string) bool {
+	base := strings.TrimSuffix(filepath.Base(video), filepath.Ext(video))
+	entries, err := os.ReadDir(filepath.Dir(video))
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, base) {
+			continue
+		}
+		ext := filepath.Ext(name)
+		if !isSubtitle(ext) {
+			continue
+		}
+		rest := strings.TrimSuffix(strings.TrimPrefix(name, base), ext)
+		if rest == "" {
+			return true
+		}
+		if lang := strings.TrimPrefix(rest, "."); len(lang) < len(rest) && lang != "" && !strings.Contains(lang, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+func isSubtitle(ext string) bool {
+	for _, subtitleExt := range subtitleExtensions {
+		if strings.EqualFold(ext, subtitleExt) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Scanner) formatResults(results []Result) {
+	tbl := table.NewWriter()
+	tbl.SetOutputMirror(os.Stdout)
+	tbl.AppendHeader(table.Row{"#", "Video", "Subtitle"})
+	for i, result := range results {
+		subtitle := filepath.Base(result.Subtitle)
+		if result.Err != nil {
+			subtitle = result.Err.Error()
+		}
+		tbl.AppendRow(table.Row{i, filepath.Base(result.Video), subtitle})
+	}
+	tbl.AppendFooter(table.Row{"Total:", len(results)})
+	tbl.SetStyle(s.settings.Style)
+	tbl.Render()
+}
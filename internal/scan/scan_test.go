@@ -0,0 +1,36 @@
+package scan
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHasSubtitle(t *testing.T) {
+	tests := []struct {
+		name      string
+		subtitles []string
+		want      bool
+	}{
+		{"none", nil, false},
+		{"same name", []string{"Movie.srt"}, true},
+		{"language", []string{"Movie.es.srt"}, true},
+		{"upper case extension", []string{"Movie.en.ASS"}, true},
+		{"other cut", []string{"Movie.Directors.Cut.es.srt"}, false},
+		{"longer name", []string{"Movie2.srt"}, false},
+		{"not a subtitle", []string{"Movie.es.txt", "Movie.nfo"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range append(tt.subtitles, "Movie.mkv") {
+				if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := hasSubtitle(filepath.Join(dir, "Movie.mkv")); got != tt.want {
+				t.Errorf("hasSubtitle(%v) = %v, want %v", tt.subtitles, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrThrottled is returned once a request is still throttled after every
+// retry was used.
+var ErrThrottled = errors.New("throttled by the server")
+
+// ThrottledError is returned by a request when the server asks to slow
+// down, RetryAfter is zero when the server didn't say for how long.
+type ThrottledError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return e.Reason
+}
+
+func (e *ThrottledError) Is(target error) bool {
+	return target == ErrThrottled
+}
+
+type Options struct {
+	// Rate is the number of requests per second allowed by the bucket,
+	// zero disables the bucket.
+	Rate  float64
+	Burst int
+	// MaxRetries is how many times a throttled request is retried.
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+	// Notify is called before waiting for a throttled request.
+	Notify func(wait time.Duration, attempt int, reason string)
+}
+
+var DefaultOptions = Options{
+	Rate:       4,
+	Burst:      8,
+	MaxRetries: 6,
+	BaseDelay:  2 * time.Second,
+	MaxDelay:   time.Minute,
+}
+
+// Scheduler puts a token bucket in front of requests and retries throttled
+// ones with exponential backoff and jitter. A throttled request pauses every
+// other request going through the same scheduler.
+type Scheduler struct {
+	opts Options
+
+	mu          sync.Mutex
+	tokens      float64
+	last        time.Time
+	pausedUntil time.Time
+	random      *rand.Rand
+}
+
+func New(opts Options) *Scheduler {
+	if opts.Burst < 1 {
+		opts.Burst = 1
+	}
+	return &Scheduler{
+		opts:   opts,
+		tokens: float64(opts.Burst),
+		last:   time.Now(),
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Do waits for a token and runs fn, retrying while it is throttled.
+func (s *Scheduler) Do(ctx context.Context, fn func() error) error {
+	return s.run(ctx, true, fn)
+}
+
+// Retry runs fn without taking a token, retrying while it is throttled.
+func (s *Scheduler) Retry(ctx context.Context, fn func() error) error {
+	return s.run(ctx, false, fn)
+}
+
+func (s *Scheduler) run(ctx context.Context, limited bool, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		if err := s.wait(ctx, limited); err != nil {
+			return err
+		}
+		err := fn()
+		var throttled *ThrottledError
+		if !errors.As(err, &throttled) {
+			return err
+		}
+		if attempt >= s.opts.MaxRetries {
+			return fmt.Errorf("%w: %s, gave up after %d retries", ErrThrottled, throttled.Reason, attempt)
+		}
+
+		delay := s.backoff(attempt, throttled.RetryAfter)
+		if s.opts.Notify != nil {
+			s.opts.Notify(delay, attempt+1, throttled.Reason)
+		}
+		s.pause(delay)
+	}
+}
+
+// wait blocks until the scheduler is not paused and, when limited, until a
+// token is available.
+func (s *Scheduler) wait(ctx context.Context, limited bool) error {
+	s.mu.Lock()
+	now := time.Now()
+	delay := s.pausedUntil.Sub(now)
+	if limited && s.opts.Rate > 0 {
+		s.tokens = min(float64(s.opts.Burst), s.tokens+now.Sub(s.last).Seconds()*s.opts.Rate)
+		s.last = now
+		s.tokens--
+		if s.tokens < 0 {
+			delay = max(delay, time.Duration(-s.tokens/s.opts.Rate*float64(time.Second)))
+		}
+	}
+	s.mu.Unlock()
+
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (s *Scheduler) pause(delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if until := time.Now().Add(delay); until.After(s.pausedUntil) {
+		s.pausedUntil = until
+	}
+}
+
+// backoff doubles the base delay on every attempt and keeps a random half
+// of it, so concurrent requests don't retry all at once.
+func (s *Scheduler) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	delay := s.opts.BaseDelay << attempt
+	if delay <= 0 || delay > s.opts.MaxDelay {
+		delay = s.opts.MaxDelay
+	}
+	s.mu.Lock()
+	jitter := time.Duration(s.random.Int63n(int64(delay/2) + 1))
+	s.mu.Unlock()
+	return max(delay/2+jitter, retryAfter)
+}
@@ -0,0 +1,156 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBucket(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		limited bool
+		calls   int
+		atLeast time.Duration
+		atMost  time.Duration
+	}{
+		{"burst", Options{Rate: 10, Burst: 3}, true, 3, 0, 50 * time.Millisecond},
+		{"over the burst", Options{Rate: 100, Burst: 2}, true, 6, 35 * time.Millisecond, time.Second},
+		{"disabled", Options{}, true, 100, 0, 50 * time.Millisecond},
+		{"retry takes no token", Options{Rate: 1, Burst: 1}, false, 10, 0, 50 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(tt.opts)
+			start := time.Now()
+			for i := 0; i < tt.calls; i++ {
+				err := s.run(context.Background(), tt.limited, func() error { return nil })
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if elapsed := time.Since(start); elapsed < tt.atLeast || elapsed > tt.atMost {
+				t.Errorf("%d calls took %v, want between %v and %v", tt.calls, elapsed, tt.atLeast, tt.atMost)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	s := New(Options{BaseDelay: 10 * time.Millisecond, MaxDelay: 40 * time.Millisecond})
+	tests := []struct {
+		name       string
+		attempt    int
+		retryAfter time.Duration
+		min, max   time.Duration
+	}{
+		{"first", 0, 0, 5 * time.Millisecond, 10 * time.Millisecond},
+		{"doubled", 1, 0, 10 * time.Millisecond, 20 * time.Millisecond},
+		{"capped", 3, 0, 20 * time.Millisecond, 40 * time.Millisecond},
+		{"overflow capped", 70, 0, 20 * time.Millisecond, 40 * time.Millisecond},
+		{"retry after", 0, time.Second, time.Second, time.Second},
+		{"retry after shorter", 3, time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 50; i++ {
+				if got := s.backoff(tt.attempt, tt.retryAfter); got < tt.min || got > tt.max {
+					t.Fatalf("backoff(%d, %v) = %v, want between %v and %v", tt.attempt, tt.retryAfter, got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
+
+func TestRetries(t *testing.T) {
+	failure := errors.New("not found")
+	tests := []struct {
+		name       string
+		throttled  int
+		retryAfter time.Duration
+		err        error
+		calls      int
+		atLeast    time.Duration
+		wantErr    error
+	}{
+		{name: "success", calls: 1},
+		{name: "other error", err: failure, calls: 1, wantErr: failure},
+		{name: "throttled then success", throttled: 2, calls: 3},
+		{name: "retry after", throttled: 1, retryAfter: 50 * time.Millisecond, calls: 2, atLeast: 50 * time.Millisecond},
+		{name: "gave up", throttled: 10, calls: 4, wantErr: ErrThrottled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var notified []int
+			s := New(Options{
+				MaxRetries: 3,
+				BaseDelay:  time.Millisecond,
+				MaxDelay:   4 * time.Millisecond,
+				Notify: func(wait time.Duration, attempt int, reason string) {
+					if wait < tt.retryAfter {
+						t.Errorf("notified a wait of %v, want at least %v", wait, tt.retryAfter)
+					}
+					notified = append(notified, attempt)
+				},
+			})
+			calls := 0
+			start := time.Now()
+			err := s.Do(context.Background(), func() error {
+				calls++
+				if calls <= tt.throttled {
+					return &ThrottledError{Reason: "slow down", RetryAfter: tt.retryAfter}
+				}
+				return tt.err
+			})
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("Do() error = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.calls {
+				t.Errorf("fn called %d times, want %d", calls, tt.calls)
+			}
+			if len(notified) != tt.calls-1 {
+				t.Errorf("notified %v, want %d retries", notified, tt.calls-1)
+			}
+			for i, attempt := range notified {
+				if attempt != i+1 {
+					t.Errorf("notified attempt %d, want %d", attempt, i+1)
+				}
+			}
+			if elapsed := time.Since(start); elapsed < tt.atLeast {
+				t.Errorf("took %v, want at least %v", elapsed, tt.atLeast)
+			}
+		})
+	}
+}
+
+func TestCancelDuringPause(t *testing.T) {
+	s := New(Options{MaxRetries: 3, BaseDelay: time.Hour, MaxDelay: time.Hour})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := s.Do(ctx, func() error {
+		return &ThrottledError{Reason: "slow down"}
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Do() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cancelled pause returned after %v", elapsed)
+	}
+
+	// the pause holds back every request of the scheduler, not only the
+	// throttled one
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	called := false
+	err = s.Retry(ctx, func() error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) || called {
+		t.Errorf("Retry() during the pause = %v, called %v", err, called)
+	}
+}
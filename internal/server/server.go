@@ -0,0 +1,209 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	files "github.com/xochilpili/subtitler-cli/internal/files"
+	"github.com/xochilpili/subtitler-cli/internal/flags"
+	httpclient "github.com/xochilpili/subtitler-cli/internal/http-client"
+	"github.com/xochilpili/subtitler-cli/internal/logger"
+	"github.com/xochilpili/subtitler-cli/internal/service"
+)
+
+type Server struct {
+	settings *flags.OptionFlags
+	provider service.Provider
+	mux      *http.ServeMux
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// New returns a server exposing search, comments and download of the
+// given provider as a REST api.
+func New(settings *flags.OptionFlags, provider service.Provider) *Server {
+	s := &Server{
+		settings: settings,
+		provider: provider,
+		mux:      http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/search", s.search)
+	s.mux.HandleFunc("/subtitles/", s.subtitles)
+	return s
+}
+
+func (s *Server) Handler() http.Handler {
+	return s.recoverer(s.mux)
+}
+
+// ListenAndServe serves the api on addr until ctx is cancelled.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errs := make(chan error, 1)
+	go func() {
+		logger.Info("%v: %v", "listening on", addr)
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+}
+
+// GET /search?q=title
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		s.methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		s.error(w, http.StatusBadRequest, errors.New("missing q parameter"))
+		return
+	}
+	subtitles, err := s.provider.GetSubtitles(r.Context(), query)
+	if err != nil {
+		s.providerError(w, err)
+		return
+	}
+	if subtitles == nil {
+		subtitles = []service.Subtitles{}
+	}
+	s.json(w, http.StatusOK, subtitles)
+}
+
+// GET /subtitles/{id}/comments and POST /subtitles/{id}/download
+func (s *Server) subtitles(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/subtitles/"), "/"), "/")
+	if len(parts) != 2 {
+		s.error(w, http.StatusNotFound, fmt.Errorf("unknown path %s", r.URL.Path))
+		return
+	}
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || id <= 0 {
+		s.error(w, http.StatusBadRequest, fmt.Errorf("invalid subtitle id %q", parts[0]))
+		return
+	}
+
+	switch parts[1] {
+	case "comments":
+		if r.Method != http.MethodGet {
+			s.methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		comments, err := s.provider.GetComments(r.Context(), id)
+		if err != nil {
+			s.providerError(w, err)
+			return
+		}
+		if comments == nil {
+			comments = []service.SubComments{}
+		}
+		s.json(w, http.StatusOK, comments)
+	case "download":
+		if r.Method != http.MethodPost {
+			s.methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		downloaded, err := s.download(r.Context(), id)
+		if err != nil {
+			s.providerError(w, err)
+			return
+		}
+		s.json(w, http.StatusOK, service.NewDownload(id, downloaded))
+	default:
+		s.error(w, http.StatusNotFound, fmt.Errorf("unknown path %s", r.URL.Path))
+	}
+}
+
+// download fetches and unpacks id in a directory of its own, so requests
+// for the same id don't overwrite or remove each other's archive, and moves
+// the subtitles to the download path.
+func (s *Server) download(ctx context.Context, id int) ([]files.Result, error) {
+	tmp, err := os.MkdirTemp(s.settings.DownloadPath, ".subtitler-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	downloaded, err := s.provider.DownloadSubtitle(ctx, id, tmp)
+	if err != nil {
+		return nil, err
+	}
+	for i, file := range downloaded {
+		target := filepath.Join(s.settings.DownloadPath, filepath.Base(file.Path))
+		if err := os.Rename(file.Path, target); err != nil {
+			return nil, err
+		}
+		downloaded[i].Path = target
+	}
+	return downloaded, nil
+}
+
+func (s *Server) json(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Error("%v %v", "error encoding response:", err.Error())
+	}
+}
+
+func (s *Server) error(w http.ResponseWriter, status int, err error) {
+	if s.settings.Debug {
+		logger.Debug("%v: %d %v", "request failed", status, err)
+	}
+	s.json(w, status, errorResponse{Error: err.Error()})
+}
+
+// providerError maps the provider errors to the closest status, anything
+// unexpected from upstream is a bad gateway.
+func (s *Server) providerError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, httpclient.ErrNotFound):
+		s.error(w, http.StatusNotFound, err)
+	case errors.Is(err, httpclient.ErrThrottled):
+		s.error(w, http.StatusServiceUnavailable, err)
+	case errors.Is(err, files.ErrUnsafeEntry), errors.Is(err, files.ErrTooLarge), errors.Is(err, files.ErrUnknownArchive):
+		// the download worked but its archive can't be unpacked
+		s.error(w, http.StatusUnprocessableEntity, err)
+	default:
+		s.error(w, http.StatusBadGateway, err)
+	}
+}
+
+func (s *Server) methodNotAllowed(w http.ResponseWriter, allowed string) {
+	w.Header().Set("Allow", allowed)
+	s.error(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+}
+
+// recoverer turns a panic while serving a request into a 500 response
+// instead of taking the whole server down.
+func (s *Server) recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				logger.Error("%v %v", "panic serving "+r.URL.Path+":", fmt.Sprint(rec))
+				s.error(w, http.StatusInternalServerError, errors.New("internal server error"))
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	files "github.com/xochilpili/subtitler-cli/internal/files"
+	"github.com/xochilpili/subtitler-cli/internal/flags"
+	httpclient "github.com/xochilpili/subtitler-cli/internal/http-client"
+	"github.com/xochilpili/subtitler-cli/internal/service"
+)
+
+// fakeProvider answers every call with err, or with a subtitle named after
+// the id for downloads.
+type fakeProvider struct {
+	err error
+}
+
+func (p *fakeProvider) GetSubtitles(ctx context.Context, title string) ([]service.Subtitles, error) {
+	return []service.Subtitles{{Id: 1, Title: title}}, p.err
+}
+
+func (p *fakeProvider) SearchPage(ctx context.Context, title string, page int) (*service.Page, error) {
+	return nil, p.err
+}
+
+func (p *fakeProvider) GetComments(ctx context.Context, subtitleId int) ([]service.SubComments, error) {
+	return nil, p.err
+}
+
+// DownloadSubtitle writes the archive to path like subdivx does and checks
+// nobody else touched it before unpacking it.
+func (p *fakeProvider) DownloadSubtitle(ctx context.Context, subtitleId int, path string) ([]files.Result, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	archive := filepath.Join(path, fmt.Sprintf("%d.zip", subtitleId))
+	content := []byte(path)
+	if err := os.WriteFile(archive, content, 0644); err != nil {
+		return nil, err
+	}
+	time.Sleep(10 * time.Millisecond)
+	if data, err := os.ReadFile(archive); err != nil || string(data) != string(content) {
+		return nil, fmt.Errorf("archive changed while unpacking: %v", err)
+	}
+	os.Remove(archive)
+	subtitle := filepath.Join(path, fmt.Sprintf("%d.srt", subtitleId))
+	if err := os.WriteFile(subtitle, []byte("1\n00:00:01,000 --> 00:00:02,000\nHola\n"), 0644); err != nil {
+		return nil, err
+	}
+	return []files.Result{{Path: subtitle, Written: files.EncodingUTF8}}, nil
+}
+
+func newTestServer(t *testing.T, err error) (*httptest.Server, string) {
+	t.Helper()
+	dir := t.TempDir()
+	server := httptest.NewServer(New(&flags.OptionFlags{DownloadPath: dir}, &fakeProvider{err: err}).Handler())
+	t.Cleanup(server.Close)
+	return server, dir
+}
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		method string
+		path   string
+		status int
+	}{
+		{"search", nil, http.MethodGet, "/search?q=movie", http.StatusOK},
+		{"missing query", nil, http.MethodGet, "/search?q=+", http.StatusBadRequest},
+		{"search method", nil, http.MethodPost, "/search?q=movie", http.StatusMethodNotAllowed},
+		{"comments method", nil, http.MethodPost, "/subtitles/1/comments", http.StatusMethodNotAllowed},
+		{"download method", nil, http.MethodGet, "/subtitles/1/download", http.StatusMethodNotAllowed},
+		{"invalid id", nil, http.MethodGet, "/subtitles/abc/comments", http.StatusBadRequest},
+		{"negative id", nil, http.MethodGet, "/subtitles/-1/comments", http.StatusBadRequest},
+		{"unknown path", nil, http.MethodGet, "/subtitles/1/other", http.StatusNotFound},
+		{"not found", fmt.Errorf("subtitle 1: %w", httpclient.ErrNotFound), http.MethodGet, "/subtitles/1/comments", http.StatusNotFound},
+		{"throttled", fmt.Errorf("search: %w", httpclient.ErrThrottled), http.MethodGet, "/search?q=movie", http.StatusServiceUnavailable},
+		{"unsafe archive", fmt.Errorf("processing: %w", files.ErrUnsafeEntry), http.MethodPost, "/subtitles/1/download", http.StatusUnprocessableEntity},
+		{"archive too large", fmt.Errorf("processing: %w", files.ErrTooLarge), http.MethodPost, "/subtitles/1/download", http.StatusUnprocessableEntity},
+		{"unknown archive", fmt.Errorf("processing: %w", files.ErrUnknownArchive), http.MethodPost, "/subtitles/1/download", http.StatusUnprocessableEntity},
+		{"upstream", errors.New("connection reset"), http.MethodGet, "/search?q=movie", http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := newTestServer(t, tt.err)
+			req, _ := http.NewRequest(tt.method, server.URL+tt.path, nil)
+			res, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			if res.StatusCode != tt.status {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.path, res.StatusCode, tt.status)
+			}
+			if tt.status == http.StatusMethodNotAllowed && res.Header.Get("Allow") == "" {
+				t.Error("405 without an Allow header")
+			}
+			if tt.status != http.StatusOK {
+				var body errorResponse
+				if err := json.NewDecoder(res.Body).Decode(&body); err != nil || body.Error == "" {
+					t.Errorf("error body = %+v, %v", body, err)
+				}
+			}
+		})
+	}
+}
+
+func TestConcurrentDownloads(t *testing.T) {
+	server, dir := newTestServer(t, nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := http.Post(server.URL+"/subtitles/7/download", "", nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer res.Body.Close()
+			var download service.Download
+			if err := json.NewDecoder(res.Body).Decode(&download); err != nil || res.StatusCode != http.StatusOK {
+				t.Errorf("download = %d %+v, %v", res.StatusCode, download, err)
+				return
+			}
+			if len(download.Files) != 1 || download.Files[0].Path != filepath.Join(dir, "7.srt") {
+				t.Errorf("download files = %+v", download.Files)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// only the subtitle is left, the directories of the requests are gone
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != "7.srt" {
+		t.Errorf("download path has %v", entries)
+	}
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/xochilpili/subtitler-cli/internal/flags"
+)
+
+// Filter drops the subtitles that don't meet the minimum downloads, the
+// number of CDs or that don't mention the wanted words.
+type Filter struct {
+	MinDownloads int
+	Cds          int
+	// Match words must all appear in the title or description, Exclude words
+	// must not appear in any of them.
+	Match   []string
+	Exclude []string
+}
+
+func NewFilter(settings *flags.OptionFlags) Filter {
+	return Filter{
+		MinDownloads: settings.MinDownloads,
+		Cds:          settings.Cds,
+		Match:        settings.Match,
+		Exclude:      settings.Exclude,
+	}
+}
+
+// Apply returns the subtitles accepted by the filter, keeping their order.
+func (f Filter) Apply(subtitles []Subtitles) []Subtitles {
+	match := wordRegexps(f.Match)
+	exclude := wordRegexps(f.Exclude)
+	filtered := make([]Subtitles, 0, len(subtitles))
+Subtitles:
+	for _, subtitle := range subtitles {
+		if subtitle.Downloads < f.MinDownloads || (f.Cds > 0 && subtitle.Cds != f.Cds) {
+			continue
+		}
+		text := subtitle.Title + " " + subtitle.Description
+		for _, re := range match {
+			if !re.MatchString(text) {
+				continue Subtitles
+			}
+		}
+		for _, re := range exclude {
+			if re.MatchString(text) {
+				continue Subtitles
+			}
+		}
+		filtered = append(filtered, subtitle)
+	}
+	return filtered
+}
+
+// String describes the active filters, empty when there are none.
+func (f Filter) String() string {
+	var parts []string
+	if f.MinDownloads > 0 {
+		parts = append(parts, fmt.Sprintf("min-downloads %d", f.MinDownloads))
+	}
+	if f.Cds > 0 {
+		parts = append(parts, fmt.Sprintf("cds %d", f.Cds))
+	}
+	for _, word := range f.Match {
+		parts = append(parts, "+"+word)
+	}
+	for _, word := range f.Exclude {
+		parts = append(parts, "-"+word)
+	}
+	return strings.Join(parts, " ")
+}
+
+// wordRegexps matches every word case insensitively and as a whole word, so
+// CAM doesn't exclude CAMPEONES.
+func wordRegexps(words []string) []*regexp.Regexp {
+	res := make([]*regexp.Regexp, 0, len(words))
+	for _, word := range words {
+		res = append(res, regexp.MustCompile(`(?i)(^|[^\pL\pN])`+regexp.QuoteMeta(word)+`($|[^\pL\pN])`))
+	}
+	return res
+}
+
+// SortSubtitles orders subtitles by one of the flags.Sort* keys, best or
+// newest first. Relevance ranks against the searched release and falls
+// back to the scorer, leaving ties in the provider order.
+func SortSubtitles(by string, settings *flags.OptionFlags, subtitles []Subtitles) error {
+	switch by {
+	case flags.SortRelevance:
+		if settings.Release != nil {
+			RankSubtitles(settings.Release, subtitles)
+			return nil
+		}
+		scorer := NewScorer(settings)
+		scores := make(map[int]float64, len(subtitles))
+		for _, subtitle := range subtitles {
+			scores[subtitle.Id] = scorer.Score(subtitle)
+		}
+		sort.SliceStable(subtitles, func(i, j int) bool {
+			return scores[subtitles[i].Id] > scores[subtitles[j].Id]
+		})
+	case flags.SortDownloads:
+		sort.SliceStable(subtitles, func(i, j int) bool {
+			return subtitles[i].Downloads > subtitles[j].Downloads
+		})
+	case flags.SortCds:
+		sort.SliceStable(subtitles, func(i, j int) bool {
+			return subtitles[i].Cds < subtitles[j].Cds
+		})
+	case flags.SortDate:
+		// the upload date isn't always sent, ids grow with every upload
+		sort.SliceStable(subtitles, func(i, j int) bool {
+			if subtitles[i].Date != subtitles[j].Date {
+				return subtitles[i].Date > subtitles[j].Date
+			}
+			return subtitles[i].Id > subtitles[j].Id
+		})
+	default:
+		return fmt.Errorf("unknown sort %q, expected %s", by, strings.Join(flags.Sorts, ", "))
+	}
+	return nil
+}
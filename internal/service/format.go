@@ -0,0 +1,227 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/jedib0t/go-pretty/v6/table"
+	files "github.com/xochilpili/subtitler-cli/internal/files"
+	"github.com/xochilpili/subtitler-cli/internal/flags"
+	"github.com/xochilpili/subtitler-cli/internal/media"
+)
+
+// Formatter renders search results and downloaded files.
+type Formatter interface {
+	FormatSubtitles(subtitles []Subtitles)
+	FormatComments(subtitles []Subtitles)
+	FormatDownloadedFiles(downloads []Download)
+}
+
+// Download is the machine readable result of a download, every file with
+// the encoding it was found and written in.
+type Download struct {
+	Id    int            `json:"id"`
+	Files []files.Result `json:"files"`
+}
+
+// Comments is the machine readable list of comments of a subtitle.
+type Comments struct {
+	Id       int           `json:"id"`
+	Comments []SubComments `json:"comments"`
+}
+
+// NewDownload returns the machine readable result of downloading subtitleId.
+func NewDownload(subtitleId int, downloaded []files.Result) Download {
+	if downloaded == nil {
+		downloaded = []files.Result{}
+	}
+	return Download{Id: subtitleId, Files: downloaded}
+}
+
+// NewFormatter returns the formatter for the -o flag, tables are the default.
+func NewFormatter(settings *flags.OptionFlags) Formatter {
+	switch settings.Output {
+	case flags.OutputJSON, flags.OutputNDJSON:
+		return &jsonFormatter{
+			w:      os.Stdout,
+			ndjson: settings.Output == flags.OutputNDJSON,
+		}
+	}
+	return &tableFormatter{
+		style:       settings.Style,
+		highlighter: NewHighlighter(settings.Releases),
+	}
+}
+
+type tableFormatter struct {
+	style       table.Style
+	highlighter *Highlighter
+}
+
+func (t *tableFormatter) FormatSubtitles(subtitles []Subtitles) {
+	tbl := table.NewWriter()
+	tbl.SetOutputMirror(os.Stdout)
+	tbl.AppendHeader(table.Row{"#", "ID", "Title", "Description", "Downloads", "CDs"})
+
+	for i, item := range subtitles {
+		if item.Title != "" {
+			tbl.AppendRow(table.Row{i, item.Id, t.highlighter.Highlight(item.Title), t.highlighter.Highlight(item.Description), item.Downloads, item.Cds})
+			tbl.AppendSeparator()
+			if item.Comments != nil {
+				for _, comment := range *item.Comments {
+					if comment.Comment != "" {
+						tbl.AppendRow(table.Row{"", "", comment.Nick, t.highlighter.Highlight(comment.Comment)})
+					}
+				}
+			}
+			tbl.AppendSeparator()
+		}
+	}
+	tbl.SetStyle(t.style)
+	tbl.SetAllowedRowLength(300)
+	tbl.Render()
+}
+
+func (t *tableFormatter) FormatComments(subtitles []Subtitles) {
+	for _, subtitle := range subtitles {
+		t.formatComments(subtitle)
+	}
+}
+
+func (t *tableFormatter) formatComments(subtitle Subtitles) {
+	tbl := table.NewWriter()
+	tbl.SetOutputMirror(os.Stdout)
+	tbl.SetTitle(fmt.Sprintf("%d - %s", subtitle.Id, t.highlighter.Highlight(subtitle.Title)))
+	tbl.AppendHeader(table.Row{"Nick", "Comment", "Date"})
+	comments := 0
+	if subtitle.Comments != nil {
+		for _, comment := range *subtitle.Comments {
+			if comment.Comment != "" {
+				tbl.AppendRow(table.Row{comment.Nick, t.highlighter.Highlight(comment.Comment), comment.Date})
+				comments++
+			}
+		}
+	}
+	tbl.AppendFooter(table.Row{"Total Comments:", comments})
+	tbl.SetStyle(t.style)
+	tbl.SetAllowedRowLength(300)
+	tbl.Render()
+}
+
+func (t *tableFormatter) FormatDownloadedFiles(downloads []Download) {
+	for _, download := range downloads {
+		t.formatDownload(download.Files)
+	}
+}
+
+func (t *tableFormatter) formatDownload(downloaded []files.Result) {
+	tbl := table.NewWriter()
+	tbl.SetOutputMirror(os.Stdout)
+	tbl.AppendHeader(table.Row{"#", "File", "Detected", "Confidence", "Read As", "Written"})
+	for i, item := range downloaded {
+		detected := item.Encoding
+		if item.BOM {
+			detected += " (bom)"
+		}
+		readAs := item.ReadAs()
+		if item.Override != "" {
+			readAs += " (-encoding)"
+		}
+		written := item.Written
+		if item.ReadAs() == files.EncodingUTF8 {
+			written += " (untouched)"
+		}
+		tbl.AppendSeparator()
+		tbl.AppendRow(table.Row{i, item.Path, detected, fmt.Sprintf("%.0f%%", item.Confidence*100), readAs, written})
+		tbl.AppendSeparator()
+	}
+	tbl.AppendFooter(table.Row{"Total Uncompressed:", len(downloaded)})
+	tbl.SetStyle(t.style)
+	tbl.Render()
+}
+
+type jsonFormatter struct {
+	w      io.Writer
+	ndjson bool
+}
+
+func (j *jsonFormatter) FormatSubtitles(subtitles []Subtitles) {
+	encodeList(j, subtitles)
+}
+
+func (j *jsonFormatter) FormatComments(subtitles []Subtitles) {
+	comments := make([]Comments, 0, len(subtitles))
+	for _, subtitle := range subtitles {
+		item := Comments{Id: subtitle.Id, Comments: []SubComments{}}
+		if subtitle.Comments != nil && *subtitle.Comments != nil {
+			item.Comments = *subtitle.Comments
+		}
+		comments = append(comments, item)
+	}
+	encodeList(j, comments)
+}
+
+func (j *jsonFormatter) FormatDownloadedFiles(downloads []Download) {
+	encodeList(j, downloads)
+}
+
+// encodeList writes items as a single array, or one item per line for
+// ndjson.
+func encodeList[T any](j *jsonFormatter, items []T) {
+	if items == nil {
+		items = []T{}
+	}
+	if !j.ndjson {
+		j.encode(items)
+		return
+	}
+	for _, item := range items {
+		j.encode(item)
+	}
+}
+
+func (j *jsonFormatter) encode(v interface{}) {
+	encoder := json.NewEncoder(j.w)
+	if !j.ndjson {
+		encoder.SetIndent("", "  ")
+	}
+	if err := encoder.Encode(v); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+// Highlighter highlights release groups in the rendered text.
+type Highlighter struct {
+	re        *regexp.Regexp
+	highlight func(a ...interface{}) string
+}
+
+// NewHighlighter compiles the releases to highlight once, the given releases
+// are matched literally and replace the built-in list of groups.
+func NewHighlighter(releases []string) *Highlighter {
+	pattern := media.ReleaseGroups
+	if len(releases) > 0 {
+		quoted := make([]string, len(releases))
+		for i, r := range releases {
+			quoted[i] = regexp.QuoteMeta(r)
+		}
+		pattern = strings.Join(quoted, "|")
+	}
+	return &Highlighter{
+		re:        regexp.MustCompile("(?mi)" + pattern),
+		highlight: color.New(color.FgHiYellow, color.BgHiBlack).SprintFunc(),
+	}
+}
+
+// Highlight returns input with the release groups highlighted and its
+// whitespace collapsed.
+func (h *Highlighter) Highlight(input string) string {
+	return h.re.ReplaceAllStringFunc(strings.Join(strings.Fields(input), " "), func(match string) string {
+		return h.highlight(match)
+	})
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	files "github.com/xochilpili/subtitler-cli/internal/files"
+)
+
+func TestFormatDownloadedFilesJSON(t *testing.T) {
+	downloads := []Download{
+		NewDownload(1, []files.Result{{Path: "1/Movie.srt", Written: files.EncodingUTF8}}),
+		NewDownload(2, nil),
+	}
+
+	var out bytes.Buffer
+	(&jsonFormatter{w: &out}).FormatDownloadedFiles(downloads)
+	var document []Download
+	if err := json.Unmarshal(out.Bytes(), &document); err != nil {
+		t.Fatalf("json output is not one document: %v\n%s", err, out.String())
+	}
+	if len(document) != 2 || document[0].Id != 1 || document[1].Id != 2 || document[1].Files == nil {
+		t.Errorf("json output = %+v", document)
+	}
+
+	out.Reset()
+	(&jsonFormatter{w: &out, ndjson: true}).FormatDownloadedFiles(downloads)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("ndjson output has %d lines, want 2:\n%s", len(lines), out.String())
+	}
+	for i, line := range lines {
+		var download Download
+		if err := json.Unmarshal([]byte(line), &download); err != nil || download.Id != downloads[i].Id {
+			t.Errorf("ndjson line %d = %s, %v", i, line, err)
+		}
+	}
+}
+
+func TestFormatCommentsJSON(t *testing.T) {
+	comments := []SubComments{{Nick: "nick", Comment: "sincroniza bien"}}
+	subtitles := []Subtitles{{Id: 1, Comments: &comments}, {Id: 2}}
+
+	var out bytes.Buffer
+	(&jsonFormatter{w: &out}).FormatComments(subtitles)
+	var document []Comments
+	if err := json.Unmarshal(out.Bytes(), &document); err != nil {
+		t.Fatalf("json output is not one document: %v\n%s", err, out.String())
+	}
+	if len(document) != 2 || document[0].Id != 1 || len(document[0].Comments) != 1 || document[1].Id != 2 || document[1].Comments == nil {
+		t.Errorf("json output = %+v", document)
+	}
+
+	out.Reset()
+	(&jsonFormatter{w: &out, ndjson: true}).FormatComments(subtitles)
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 2 || !strings.HasPrefix(lines[0], `{"id":1,"comments":[`) {
+		t.Errorf("ndjson output = %s", out.String())
+	}
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	files "github.com/xochilpili/subtitler-cli/internal/files"
+	"github.com/xochilpili/subtitler-cli/internal/flags"
+)
+
+// Provider is implemented by every subtitle site the cli can search on.
+// GetSubtitles may return the results found before an error along with it.
+type Provider interface {
+	GetSubtitles(ctx context.Context, title string) ([]Subtitles, error)
+	SearchPage(ctx context.Context, title string, page int) (*Page, error)
+	GetComments(ctx context.Context, subtitleId int) ([]SubComments, error)
+	DownloadSubtitle(ctx context.Context, subtitleId int, path string) ([]files.Result, error)
+}
+
+// ProviderFactory builds a provider from the parsed cli settings.
+type ProviderFactory func(settings *flags.OptionFlags) Provider
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[string]ProviderFactory{}
+)
+
+// Register makes a provider available by name, it panics when the name is
+// registered twice.
+func Register(name string, factory ProviderFactory) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	if factory == nil {
+		panic("service: register provider factory is nil")
+	}
+	if _, dup := providers[name]; dup {
+		panic("service: register called twice for provider " + name)
+	}
+	providers[name] = factory
+}
+
+// NewProvider returns the provider registered under name.
+func NewProvider(name string, settings *flags.OptionFlags) (Provider, error) {
+	providersMu.RLock()
+	factory, ok := providers[name]
+	providersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q, available: %v", name, Providers())
+	}
+	return factory(settings), nil
+}
+
+// Providers returns the sorted names of the registered providers.
+func Providers() []string {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"sort"
+
+	"github.com/xochilpili/subtitler-cli/internal/media"
+)
+
+// RankSubtitles sorts subtitles by how well their title and description
+// match the release parsed from a video file, best match first.
+func RankSubtitles(release *media.Release, subtitles []Subtitles) {
+	if release == nil {
+		return
+	}
+	sort.SliceStable(subtitles, func(i, j int) bool {
+		return release.Score(subtitles[i].Title+" "+subtitles[i].Description) >
+			release.Score(subtitles[j].Title+" "+subtitles[j].Description)
+	})
+}
+
+// FilterEpisode keeps the subtitles of the given episode, including the
+// packs of its whole season.
+func FilterEpisode(episode media.Episode, subtitles []Subtitles) []Subtitles {
+	var filtered []Subtitles
+	for _, subtitle := range subtitles {
+		if subtitle.Season == 0 {
+			continue
+		}
+		if episode.Matches(media.Episode{Season: subtitle.Season, Episode: subtitle.Episode}) {
+			filtered = append(filtered, subtitle)
+		}
+	}
+	return filtered
+}
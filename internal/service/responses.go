@@ -1,8 +1,7 @@
 package service
 
 type Token struct {
-	Cookie string `json:"cookie,omitempty"`
-	Token  string `json:"token"`
+	Token string `json:"token"`
 }
 
 type SubComments struct {
@@ -19,6 +18,7 @@ type SubData struct {
 	Cds         int    `json:"cds"`
 	Downloads   int    `json:"descargas"`
 	Comments    int    `json:"comentarios"`
+	Date        string `json:"fecha_subida"`
 }
 
 type SubdivxResponse[T any] struct {
@@ -26,7 +26,7 @@ type SubdivxResponse[T any] struct {
 	ITotalRecords        int    `json:"iTotalRecords"`
 	ITotalDisplayRecords int    `json:"iTotalDisplayRecords"`
 	Data                 []T    `json:"aaData"`
-	Message				string `json:"mensaje,omitempty"`
+	Message              string `json:"mensaje,omitempty"`
 }
 
 type SubdivxSubPayload struct {
@@ -45,5 +45,32 @@ type Subtitles struct {
 	Title       string         `json:"title"`
 	Description string         `json:"description"`
 	Cds         int            `json:"cds"`
+	Downloads   int            `json:"downloads"`
+	Date        string         `json:"date,omitempty"`
+	Season      int            `json:"season,omitempty"`
+	Episode     int            `json:"episode,omitempty"`
 	Comments    *[]SubComments `json:"comments,omitempty"`
+	// CommentsError is set when the comments of the subtitle couldn't be fetched.
+	CommentsError string `json:"comments_error,omitempty"`
+}
+
+// Page is one page of search results.
+type Page struct {
+	Subtitles []Subtitles `json:"subtitles"`
+	Number    int         `json:"page"`
+	Size      int         `json:"page_size"`
+	Total     int         `json:"total"`
+}
+
+// Pages returns the number of pages of the search.
+func (p *Page) Pages() int {
+	if p.Size < 1 {
+		return 1
+	}
+	return max((p.Total+p.Size-1)/p.Size, 1)
+}
+
+// HasNext reports whether there are results after this page.
+func (p *Page) HasNext() bool {
+	return p.Number < p.Pages()
 }
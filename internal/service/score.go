@@ -0,0 +1,107 @@
+package service
+
+import (
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/xochilpili/subtitler-cli/internal/flags"
+	"github.com/xochilpili/subtitler-cli/internal/media"
+)
+
+var (
+	qualityRe    = regexp.MustCompile(`(?i)\b(480p|576p|720p|1080[pi]|2160p|4k|web-?dl|web-?rip|blu-?ray|bdrip|brrip|hdtv|dvdrip|hdrip)\b`)
+	groupsRe     = regexp.MustCompile(`(?i)\b(` + media.ReleaseGroups + `)\b`)
+	positiveRe   = regexp.MustCompile(`(?i)gracias|perfect|excelente|funciona|sincroniza|thanks|buen[oa]?s?\b|genial|ok\b`)
+	negativeRe   = regexp.MustCompile(`(?i)no (sincroniza|funciona|coincide|sirve|va)|desfasad|desincroniz|mal(o|a|os|as)?\b|error|traducci[oó]n autom[aá]tica|google translate`)
+	sentimentCap = 5
+)
+
+// Scorer rates subtitles against the preferred releases and, when searching
+// by video file, against the parsed release.
+type Scorer struct {
+	preferred *regexp.Regexp
+	release   *media.Release
+}
+
+func NewScorer(settings *flags.OptionFlags) *Scorer {
+	scorer := &Scorer{release: settings.Release}
+	if len(settings.Releases) > 0 {
+		quoted := make([]string, len(settings.Releases))
+		for i, r := range settings.Releases {
+			quoted[i] = regexp.QuoteMeta(r)
+		}
+		scorer.preferred = regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`)
+	}
+	return scorer
+}
+
+// Score returns the weighted score of a subtitle, higher is better.
+func (sc *Scorer) Score(subtitle Subtitles) float64 {
+	text := subtitle.Title + " " + subtitle.Description
+	score := 0.0
+
+	// release groups: the ones given by -r weight more than the built-in list
+	if sc.preferred != nil {
+		score += 10 * float64(countDistinct(sc.preferred, text))
+	}
+	score += 2 * math.Min(float64(countDistinct(groupsRe, text)), 3)
+
+	// resolution and source tokens
+	if sc.release != nil {
+		score += float64(sc.release.Score(text))
+	} else {
+		score += math.Min(float64(countDistinct(qualityRe, subtitle.Description)), 3)
+	}
+
+	// downloads on a logarithmic scale so popular subtitles don't always win
+	score += 2 * math.Log10(float64(subtitle.Downloads)+1)
+
+	score += 1.5 * float64(sentiment(subtitle.Comments))
+	return score
+}
+
+// Best returns the index of the highest scoring subtitle, or -1 when the
+// slice is empty.
+func (sc *Scorer) Best(subtitles []Subtitles) int {
+	best := -1
+	bestScore := math.Inf(-1)
+	for i, subtitle := range subtitles {
+		if score := sc.Score(subtitle); score > bestScore {
+			best = i
+			bestScore = score
+		}
+	}
+	return best
+}
+
+func sentiment(comments *[]SubComments) int {
+	if comments == nil {
+		return 0
+	}
+	total := 0
+	for _, comment := range *comments {
+		text := comment.Comment
+		switch {
+		case negativeRe.MatchString(text):
+			total--
+		case positiveRe.MatchString(text):
+			total++
+		}
+	}
+	if total > sentimentCap {
+		return sentimentCap
+	}
+	if total < -sentimentCap {
+		return -sentimentCap
+	}
+	return total
+}
+
+func countDistinct(re *regexp.Regexp, text string) int {
+	seen := map[string]bool{}
+	for _, match := range re.FindAllString(text, -1) {
+		seen[strings.ToLower(match)] = true
+	}
+	return len(seen)
+}
@@ -2,86 +2,98 @@ package service
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
-	"github.com/fatih/color"
-	"github.com/go-resty/resty/v2"
-	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/microcosm-cc/bluemonday"
+	"github.com/xochilpili/subtitler-cli/internal/cache"
 	files "github.com/xochilpili/subtitler-cli/internal/files"
 	"github.com/xochilpili/subtitler-cli/internal/flags"
+	httpclient "github.com/xochilpili/subtitler-cli/internal/http-client"
 	"github.com/xochilpili/subtitler-cli/internal/logger"
+	"github.com/xochilpili/subtitler-cli/internal/media"
+	"github.com/xochilpili/subtitler-cli/internal/scheduler"
+	"github.com/xochilpili/subtitler-cli/internal/worker"
 )
 
 type subdivx struct {
-	settings   *flags.OptionFlags
-	r *resty.Client
+	settings *flags.OptionFlags
+	client   httpclient.HttpClient
+	cache    *cache.Cache
+	sched    *scheduler.Scheduler
+	session  *session
 }
 
 var baseUrl = "https://subdivx.com/"
 var userAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36"
 
-func NewSub(settings *flags.OptionFlags) *subdivx {
-	r := resty.New()
-	return &subdivx{
-		settings:   settings,
-		r: r,
-	}
-}
+const throttleMessage = "Por favor espera unos segundos antes de realizar otra busqueda."
 
+// staleTokenRe matches the messages of a search rejected for its token.
+var staleTokenRe = regexp.MustCompile(`(?i)token|sesi[oó]n|recarga`)
 
-func (s *subdivx) FormatSubtitles(subtitles []Subtitles) {
-	tbl := table.NewWriter()
-	tbl.SetOutputMirror(os.Stdout)
-	tbl.AppendHeader(table.Row{"#", "ID", "Title", "Description"})
+func init() {
+	Register("subdivx", func(settings *flags.OptionFlags) Provider {
+		jar, _ := cookiejar.New(nil)
+		return NewSub(settings, httpclient.New(settings.Debug, jar))
+	})
+}
 
-	for i, item := range subtitles {
-		if item.Title != "" {
-			tbl.AppendRow(table.Row{i, item.Id, item.Title, item.Description})
-			tbl.AppendSeparator()
-			for _, comment := range *item.Comments {
-				if comment.Comment != "" {
-					tbl.AppendRow(table.Row{"", "", comment.Nick, comment.Comment})
-				}
-			}
-			tbl.AppendSeparator()
-		}
+func NewSub(settings *flags.OptionFlags, client httpclient.HttpClient) *subdivx {
+	opts := scheduler.DefaultOptions
+	opts.Rate = settings.RateLimit
+	opts.Burst = settings.Burst
+	opts.Notify = func(wait time.Duration, attempt int, reason string) {
+		logger.Info("%v: %v", "subdivx is throttling requests, retrying in",
+			fmt.Sprintf("%v (attempt %d, %s)", wait.Round(100*time.Millisecond), attempt, reason))
+	}
+	s := &subdivx{
+		settings: settings,
+		client:   client,
+		cache:    cache.Open(settings.NoCache, settings.CacheTTL),
+		sched:    scheduler.New(opts),
 	}
-	tbl.SetStyle(s.settings.Style)
-	tbl.SetAllowedRowLength(300)
-	tbl.Render()
+	s.session = newSession(s.getVersion, s.getToken)
+	return s
 }
 
-func (s *subdivx) FormatDownloadedFiles(files []*string) {
-	tbl := table.NewWriter()
-	tbl.SetOutputMirror(os.Stdout)
-	tbl.AppendHeader(table.Row{"#", "File"})
-	for i, item := range files {
-		tbl.AppendSeparator()
-		tbl.AppendRow(table.Row{i, *item})
-		tbl.AppendSeparator()
-	}
-	tbl.AppendFooter(table.Row{"Total Uncompressed:", len(files)})
-	tbl.SetStyle(s.settings.Style)
-	tbl.Render()
+// headers returns the headers of every request, cookies are sent by the
+// jar of the client.
+func (s *subdivx) headers() map[string]string {
+	return map[string]string{
+		"User-Agent":       userAgent,
+		"X-Requested-With": "XMLHttpRequest",
+	}
 }
 
+// getVersion reads the version of the search form from the home page, the
+// search field is named after it.
 func (s *subdivx) getVersion(ctx context.Context) (string, error) {
-	res, err := s.r.R().SetDebug(s.settings.Debug).SetContext(ctx).Get(baseUrl)
+	var body []byte
+	err := s.sched.Retry(ctx, func() error {
+		res, err := s.client.Get(ctx, baseUrl, s.headers())
+		if err != nil {
+			return err
+		}
+		body, err = httpclient.ReadBody(res)
+		return err
+	})
 	if err != nil {
-		return "", errors.New("error while requesting version")
+		return "", fmt.Errorf("error while requesting version: %w", err)
 	}
 	re := regexp.MustCompile(`<div[^>]*id="vs"[^>]*>([^<]+)</div>`)
-	match := re.FindStringSubmatch(string(res.Body()))
+	match := re.FindStringSubmatch(string(body))
 	if len(match) > 1 {
 		version := match[1]
 		return strings.Trim(strings.Replace(strings.TrimPrefix(version, "v"), ".", "", -1), "\n"), nil
@@ -89,138 +101,277 @@ func (s *subdivx) getVersion(ctx context.Context) (string, error) {
 	return "", errors.New("error while parsing version")
 }
 
-func (s *subdivx) getToken(ctx context.Context) (*Token, error) {
+// getToken requests the search token, the cookie it sets is kept by the jar
+// of the client, subdivx rejects searches made without it.
+func (s *subdivx) getToken(ctx context.Context) (string, error) {
 	var token Token
-	res, err := s.r.R().
-		SetContext(ctx).
-		SetHeaders(map[string]string{"Content-Type": "application/json", "User-Agent": userAgent}).
-		SetQueryParam("gt", "1").
-		SetDebug(s.settings.Debug).
-		Get(baseUrl + "inc/gt.php")
-
+	err := s.sched.Retry(ctx, func() error {
+		res, err := s.client.Get(ctx, baseUrl+"inc/gt.php?gt=1", s.headers())
+		if err != nil {
+			return err
+		}
+		token = Token{}
+		return httpclient.DecodeJSON(res, &token)
+	})
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-
-	err = json.Unmarshal(res.Body(), &token)
-	if err != nil {
-		return nil, err
+	if token.Token == "" {
+		return "", errors.New("error while requesting token: empty token")
 	}
-
-	return &token, nil
+	return token.Token, nil
 }
 
-func (s *subdivx) GetSubtitles(ctx context.Context) ([]Subtitles, error) {
-	version, _ := s.getVersion(ctx)
-	token, _ := s.getToken(ctx)
-	params := &SubdivxSubPayload{
-		Tabla:   "resultados",
-		Filtros: "",
-		Buscar:  s.settings.Title,
-		Token:   token.Token,
-	}
-	buscaVersion := fmt.Sprintf("buscar%s", version)
-	queryParams := map[string]string{
-		"tabla":      params.Tabla,
-		"filtros":    params.Filtros,
-		buscaVersion: params.Buscar,
-		"token":      params.Token,
-	}
-	var result SubdivxResponse[SubData]
-	
+// subdivxPageSize is the number of results asked for on every page.
+const subdivxPageSize = 20
+
+// subdivxMaxPages caps -all, 1000 results are more than any title has.
+const subdivxMaxPages = 50
 
-	s.r.SetRetryCount(10).SetRetryWaitTime(5*time.Second)
-	s.r.AddRetryCondition(func(r *resty.Response, _ error) bool {
-		errs := json.Unmarshal(r.Body(), &result)
-		if errs != nil{
-			return false
+// GetSubtitles returns the first page of results, or as many pages as
+// needed to fill -limit, or every page with -all. When a later page fails
+// the results gathered so far are returned with the error.
+func (s *subdivx) GetSubtitles(ctx context.Context, title string) ([]Subtitles, error) {
+	var subtitles []Subtitles
+	var pageErr error
+	seen := map[int]bool{}
+	for number := 1; number <= subdivxMaxPages; number++ {
+		page, err := s.page(ctx, title, number)
+		if err != nil {
+			if number == 1 {
+				return nil, err
+			}
+			pageErr = fmt.Errorf("page %d: %w", number, err)
+			break
 		}
-		ok, err := strconv.Atoi(result.Secho)
-		if err != nil{
-			return false
+		fresh := 0
+		for _, subtitle := range page.Subtitles {
+			if !seen[subtitle.Id] {
+				seen[subtitle.Id] = true
+				subtitles = append(subtitles, subtitle)
+				fresh++
+			}
 		}
-		return ok == 0
-	})
-	
-	
-
-	resp, err := s.r.R().
-		SetContext(ctx).
-		SetFormData(queryParams).
-		SetHeaders(map[string]string{
-			"Content-Type": "application/x-www-form-urlencoded",
-			"User-Agent":  userAgent,
-		}).
-		SetDebug(s.settings.Debug).
-		Post(baseUrl + "inc/ajax.php")
+		// a short page is the last one, a page of repeated ids means the
+		// endpoint ignores the offset
+		if !page.HasNext() || len(page.Subtitles) < subdivxPageSize || fresh == 0 {
+			break
+		}
+		if !s.settings.All && len(subtitles) >= s.settings.Limit {
+			break
+		}
+	}
+	if s.settings.Limit > 0 && len(subtitles) > s.settings.Limit {
+		subtitles = subtitles[:s.settings.Limit]
+	}
+	subtitles, err := s.withComments(ctx, subtitles)
 	if err != nil {
 		return nil, err
 	}
+	return subtitles, pageErr
+}
 
-	err = json.Unmarshal(resp.Body(), &result)
+// SearchPage returns a single page of results, pages start at 1.
+func (s *subdivx) SearchPage(ctx context.Context, title string, number int) (*Page, error) {
+	page, err := s.page(ctx, title, number)
 	if err != nil {
 		return nil, err
 	}
-
-	if s.settings.Debug {
-		logger.Debug("%v: \n%v", "GetSubtitles.PostRequest response", string(resp.Body()))
+	page.Subtitles, err = s.withComments(ctx, page.Subtitles)
+	if err != nil {
+		return nil, err
 	}
+	return page, nil
+}
 
-	if(result.Message == "Por favor espera unos segundos antes de realizar otra busqueda."){
-		
+func (s *subdivx) page(ctx context.Context, title string, number int) (*Page, error) {
+	result, err := s.search(ctx, title, number)
+	if err != nil {
+		return nil, err
 	}
 
-	var waitGroup sync.WaitGroup
-	var subtitles []Subtitles
-	subtitlesChan := make(chan Subtitles, len(result.Data))
+	subtitles := make([]Subtitles, 0, len(result.Data))
 	reg := regexp.MustCompile("\n|\r\n")
+	stripTags := bluemonday.StripTagsPolicy()
 	for _, item := range result.Data {
-		waitGroup.Add(1)
-		stripTags := bluemonday.StripTagsPolicy()
 		title := reg.ReplaceAllString(stripTags.Sanitize(item.Title), " ")
 		desc := reg.ReplaceAllString(stripTags.Sanitize(item.Description), " ")
 		subtitle := Subtitles{
 			Id:          item.Id,
-			Title:       s.HighlightString(title),
-			Description: s.HighlightString(desc),
+			Title:       title,
+			Description: desc,
 			Cds:         item.Cds,
+			Downloads:   item.Downloads,
+			Date:        item.Date,
 		}
+		if episode, ok := media.ParseEpisode(title + " " + desc); ok {
+			subtitle.Season = episode.Season
+			subtitle.Episode = episode.Episode
+		}
+		subtitles = append(subtitles, subtitle)
+	}
+
+	// iTotalDisplayRecords counts the matches of the search, iTotalRecords
+	// the whole table so it is never used. Without a count, or on a short
+	// page, the total is what was seen so far plus a page when this one is
+	// full.
+	seen := (number-1)*subdivxPageSize + len(subtitles)
+	total := result.ITotalDisplayRecords
+	if total == 0 || len(subtitles) < subdivxPageSize {
+		total = seen
+		if len(subtitles) == subdivxPageSize {
+			total++
+		}
+	}
+	return &Page{
+		Subtitles: subtitles,
+		Number:    number,
+		Size:      subdivxPageSize,
+		Total:     total,
+	}, nil
+}
 
-		go s.GetComments(ctx, &subtitle, &waitGroup, subtitlesChan)
+// withComments fills the comments of the subtitles unless they are lazy or
+// disabled.
+func (s *subdivx) withComments(ctx context.Context, subtitles []Subtitles) ([]Subtitles, error) {
+	if s.settings.Comments == flags.CommentsLazy || s.settings.Comments == flags.CommentsNone {
+		return subtitles, nil
 	}
-	waitGroup.Wait()
-	close(subtitlesChan)
 
-	for item := range subtitlesChan {
-		subtitles = append(subtitles, item)
+	// comments are fetched by a bounded pool, a failed fetch is kept on its
+	// subtitle instead of failing the whole search
+	comments, errs := worker.Map(ctx, s.settings.Concurrency, subtitles, func(ctx context.Context, subtitle Subtitles) ([]SubComments, error) {
+		return s.GetComments(ctx, subtitle.Id)
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for i := range subtitles {
+		subtitles[i].Comments = &comments[i]
+		if errs[i] != nil {
+			subtitles[i].CommentsError = errs[i].Error()
+			if s.settings.Debug {
+				logger.Debug("%v %d: %v", "error getting comments for", subtitles[i].Id, errs[i])
+			}
+		}
 	}
 	return subtitles, nil
 }
 
-func (s *subdivx) GetComments(ctx context.Context, subtitle *Subtitles, wg *sync.WaitGroup, c chan Subtitles) {
-	defer wg.Done()
-	
-	var result SubdivxResponse[SubComments]
-	res, err := s.r.R().
-		SetHeaders(map[string]string{
-			"Content-Type": "application/x-www-form-urlencoded; charset=UTF-8",
-			"User-Agent":   userAgent,
-		}).
-		SetFormData(map[string]string{
-			"getComentarios": strconv.Itoa(subtitle.Id),
-		}).
-		SetDebug(s.settings.Debug).
-		Post(baseUrl + "inc/ajax.php")
+// search posts the query for one page to the ajax endpoint, responses are
+// cached by query and page so repeated searches skip the session and the
+// request.
+func (s *subdivx) search(ctx context.Context, title string, page int) (*SubdivxResponse[SubData], error) {
+	var result SubdivxResponse[SubData]
+	key := "subdivx:" + strings.ToLower(strings.TrimSpace(title))
+	if page > 1 {
+		key += ":" + strconv.Itoa(page)
+	}
+	if s.cache.Get(cache.Searches, key, &result) {
+		return &result, nil
+	}
+
+	// a stale token is acquired again once, when the new one is rejected too
+	// the error is returned
+	for attempt := 0; ; attempt++ {
+		result, err := s.searchRequest(ctx, title, page)
+		if errors.Is(err, errStaleToken) && attempt == 0 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(result.Data) > 0 {
+			s.cache.Set(cache.Searches, key, result)
+		}
+		return result, nil
+	}
+}
+
+// searchRequest posts the query with the current session.
+func (s *subdivx) searchRequest(ctx context.Context, title string, page int) (*SubdivxResponse[SubData], error) {
+	var result SubdivxResponse[SubData]
+	version, token, err := s.session.get(ctx)
 	if err != nil {
-		logger.Error("%v: \n%v", "GetSubtitles.PostRequest response", err.Error())
+		return nil, err
+	}
+	params := &SubdivxSubPayload{
+		Tabla:   "resultados",
+		Filtros: "",
+		Buscar:  title,
+		Token:   token,
 	}
+	form := url.Values{
+		"tabla":   {params.Tabla},
+		"filtros": {params.Filtros},
+		// the search field is named after the form version, e.g. buscar397c
+		"buscar" + version: {params.Buscar},
+		"token":            {params.Token},
+		// the endpoint pages like a datatables server side source
+		"sEcho":          {strconv.Itoa(page)},
+		"iDisplayStart":  {strconv.Itoa((page - 1) * subdivxPageSize)},
+		"iDisplayLength": {strconv.Itoa(subdivxPageSize)},
+	}
+	err = s.sched.Do(ctx, func() error {
+		resp, err := s.client.PostForm(ctx, baseUrl+"inc/ajax.php", form, s.headers())
+		if err != nil {
+			if errors.Is(err, httpclient.ErrForbidden) {
+				s.session.invalidate(token)
+				return errStaleToken
+			}
+			return err
+		}
+		result = SubdivxResponse[SubData]{}
+		if err := httpclient.DecodeJSON(resp, &result); err != nil {
+			return err
+		}
 
-	err = json.Unmarshal(res.Body(), &result)
+		// the search endpoint answers with this message, or an empty sEcho,
+		// when searches are made too fast
+		if result.Message == throttleMessage || result.Secho == "0" {
+			return &scheduler.ThrottledError{Reason: "search throttled"}
+		}
+		if staleTokenRe.MatchString(result.Message) {
+			s.session.invalidate(token)
+			return errStaleToken
+		}
+		return nil
+	})
 	if err != nil {
-		logger.Error("%v: \n%v", "error getting comments", err.Error())
+		return nil, err
 	}
 
+	return &result, nil
+}
+
+func (s *subdivx) GetComments(ctx context.Context, subtitleId int) ([]SubComments, error) {
 	var comments []SubComments
+	key := "subdivx:" + strconv.Itoa(subtitleId)
+	if s.cache.Get(cache.Comments, key, &comments) {
+		return comments, nil
+	}
+
+	var result SubdivxResponse[SubComments]
+	payload := SubdivxCommentPayload{GetComments: strconv.Itoa(subtitleId)}
+	form := url.Values{"getComentarios": {payload.GetComments}}
+	err := s.sched.Do(ctx, func() error {
+		res, err := s.client.PostForm(ctx, baseUrl+"inc/ajax.php", form, s.headers())
+		if err != nil {
+			return err
+		}
+		result = SubdivxResponse[SubComments]{}
+		if err := httpclient.DecodeJSON(res, &result); err != nil {
+			return err
+		}
+		if result.Message == throttleMessage {
+			return &scheduler.ThrottledError{Reason: "comments throttled"}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	stripTags := bluemonday.StripTagsPolicy()
 	reg := regexp.MustCompile("\n|\r\n")
 	for _, comment := range result.Data {
@@ -230,75 +381,145 @@ func (s *subdivx) GetComments(ctx context.Context, subtitle *Subtitles, wg *sync
 			nick := reg.ReplaceAllString(stripTags.Sanitize(comment.Nick), " ")
 			comments = append(comments, SubComments{
 				Id:      comment.Id,
-				Comment: s.HighlightString(desc),
+				Comment: desc,
 				Nick:    nick,
 				Date:    comment.Date,
 			})
 		}
 	}
-	subtitle.Comments = &comments
-	c <- *subtitle
+	s.cache.Set(cache.Comments, key, comments)
+	return comments, nil
 }
 
-func (s *subdivx) DownloadSubtitle(ctx context.Context, subtitleId int) error {
-	id := strconv.Itoa(int(subtitleId))
-	res, err := s.r.R().
-		SetContext(ctx).
-		SetHeaders(map[string]string{
-			"User-Agent": userAgent,
-			"Referer":    baseUrl + "descargar.php",
-			"Connection": "keep-alive",
-		}).
-		SetDebug(s.settings.Debug).
-		SetDoNotParseResponse(true).
-		SetQueryParam("id", id).
-		Get(baseUrl + "descargar.php")
+func (s *subdivx) DownloadSubtitle(ctx context.Context, subtitleId int, path string) ([]files.Result, error) {
+	filename, err := s.fetchArchive(ctx, subtitleId, path)
 	if err != nil {
-		return err
-	}
-
-	contentType := res.Header().Get("Content-Type")
-	ext := strings.Split(contentType, "/")[1]
-	filename := fmt.Sprintf("%d.%s", subtitleId, ext)
-	file, err := os.Create(filename)
-	if err != nil{
-		return err
-	}
-	_, err = io.Copy(file, res.RawBody())
-	if err != nil{
-		return err
+		return nil, err
 	}
-	logger.Info("%v: \n%s", "downloaded file %s", filename)
+	logger.Info("%v: %v", "downloaded file", filename)
 	// Process downloaded files and clean (which means remove source compressed file)
+	// unless the archive is kept
 	archive := files.New(filename)
-	subtitleFles, err := archive.ProcessSubtitles(s.settings.DownloadPath, true)
+	if s.settings.Episode != nil {
+		archive.SetEpisode(*s.settings.Episode)
+	}
+	if s.settings.Format != "" {
+		archive.SetFormat(s.settings.Format, s.settings.FPS)
+	}
+	if s.settings.Encoding != "" {
+		archive.SetEncoding(s.settings.Encoding)
+	}
+	subtitleFles, err := archive.ProcessSubtitles(path, !s.settings.KeepArchive)
 	if err != nil {
-		panic(fmt.Errorf("error while processing downloaded files %v", err))
+		return nil, fmt.Errorf("error while processing downloaded files: %w", err)
 	}
-	s.FormatDownloadedFiles(subtitleFles)
 
-	return nil
+	return subtitleFles, nil
 }
 
-func (s *subdivx) HighlightString(input string) string {
-	var re *regexp.Regexp
-	if len(s.settings.Releases) < 1 {
-		re = regexp.MustCompile(`(?mi)fgt|evo|yts|yts?\.mx|yifi|yify|MkvCage|NoMeRcY|STRiFE|SiGMA|LucidTV|CHD|sujaidr|SAPHiRE|LEGI0N|hd4u|rarbg|ViSiON|ETRG|JYK|iFT|anoXmous|MkvCage|Ganool|TGx|klaxxon|icebane|greenbud1969|flawl3ss|metcon|proper|ntb|cm8|tbs|sva|avs|mtb|ion10|sauron|phoenix|minx|mvgroup|amiable|sadece|gooz|lite|killers|tbs|PHOENiX|memento|done|ExKinoRay|acool|starz|convoy|playnow|RedBlade|ntg|cmrg|cm|2hd|fty|haggis|Joy|dimension|0tv|fxg|kat|artsubs|horizon|axxo|diamond|asteroids|rarbg|unit3d|afg|xlf|pulsar|bamboozle|ebp|trump|bulit|pahe|lol|tjhd|DeeJayAhmed|DeeJahAhmed|HEVC|anoxmous|galaxy|aoc|flux|roen|silence|CiNEFiLE|wrd|rico|huzzah|RiSEHD`)
-	} else {
-		re = regexp.MustCompile("(?mi)" + strings.Join(s.settings.Releases, "|"))
-	}
-
-	matches := re.FindAllString(input, -1)
-	hightlight := color.New(color.FgHiYellow, color.BgHiBlack).SprintFunc()
-	words := strings.Fields(input)
-	for _, match := range matches {
-		reg := regexp.MustCompile("(?mi)" + match)
-		for i, w := range words {
-			if reg.MatchString(w) {
-				words[i] = reg.ReplaceAllString(w, hightlight(match))
+// fetchArchive stores the archive of subtitleId in path, from the cache when
+// it was downloaded before.
+func (s *subdivx) fetchArchive(ctx context.Context, subtitleId int, path string) (string, error) {
+	key := "subdivx:" + strconv.Itoa(subtitleId)
+	if cached, ok := s.cache.Archive(key); ok {
+		filename := filepath.Join(path, strconv.Itoa(subtitleId)+filepath.Ext(cached))
+		if err := copyFile(cached, filename); err == nil {
+			return filename, nil
+		}
+	}
+
+	endpoint := baseUrl + "descargar.php?id=" + strconv.Itoa(subtitleId)
+	headers := s.headers()
+	headers["Referer"] = baseUrl + "descargar.php"
+	var res *http.Response
+	err := s.sched.Retry(ctx, func() error {
+		var err error
+		res, err = s.client.Get(ctx, endpoint, headers)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	contentType := res.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", fmt.Errorf("unexpected content type %q for subtitle %d", contentType, subtitleId)
+	}
+	// unknown ids get the html page instead of an archive
+	if mediaType == "text/html" {
+		return "", fmt.Errorf("subtitle %d: %w", subtitleId, httpclient.ErrNotFound)
+	}
+	ext := archiveExt(mediaType)
+	if ext == ".bin" && res.Request != nil {
+		ext = urlExt(res.Request.URL)
+	}
+	filename := filepath.Join(path, strconv.Itoa(subtitleId)+ext)
+	file, err := os.Create(filename)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(file, res.Body)
+	file.Close()
+	if err != nil {
+		return "", &httpclient.NetworkError{URL: endpoint, Err: err}
+	}
+	// neither the content type nor the url told the kind, name the archive
+	// after its content so a kept one can be opened
+	if ext == ".bin" {
+		if detected, err := files.ArchiveExt(filename); err == nil && detected != "" {
+			renamed := strings.TrimSuffix(filename, ext) + detected
+			if err := os.Rename(filename, renamed); err == nil {
+				filename = renamed
 			}
 		}
 	}
-	return strings.Join(words, " ")
+	if err := s.cache.StoreArchive(key, filename); err != nil && s.settings.Debug {
+		logger.Debug("%v: %v", "error caching archive", err)
+	}
+	return filename, nil
+}
+
+// archiveExt returns the extension the archive of mediaType is saved with,
+// the archive kind is detected from its content when it is unpacked.
+func archiveExt(mediaType string) string {
+	switch mediaType {
+	case "application/zip", "application/x-zip", "application/x-zip-compressed":
+		return ".zip"
+	case "application/x-rar-compressed", "application/x-rar", "application/vnd.rar":
+		return ".rar"
+	case "application/x-7z-compressed":
+		return ".7z"
+	case "text/plain":
+		return ".txt"
+	}
+	return ".bin"
+}
+
+// urlExt returns the archive extension of the url the download was
+// redirected to, or .bin when it has none.
+func urlExt(u *url.URL) string {
+	switch ext := strings.ToLower(filepath.Ext(u.Path)); ext {
+	case ".zip", ".rar", ".7z":
+		return ext
+	}
+	return ".bin"
+}
 
+func copyFile(source string, target string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
 }
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// tokenTTL is how long a search token is used before asking for a new one,
+// long menu sessions would otherwise search with a token the site dropped.
+const tokenTTL = 30 * time.Minute
+
+// errStaleToken is returned by a search the site rejected because of the
+// token, the session is invalidated and the search retried once.
+var errStaleToken = errors.New("subdivx rejected the search token")
+
+// session keeps the search form version and token shared by every request
+// of a provider. The cookies that go with them live in the jar of the http
+// client, so searches, comments and downloads all send the same ones.
+type session struct {
+	getVersion func(ctx context.Context) (string, error)
+	getToken   func(ctx context.Context) (string, error)
+
+	mu       sync.Mutex
+	version  string
+	token    string
+	acquired time.Time
+}
+
+func newSession(getVersion, getToken func(ctx context.Context) (string, error)) *session {
+	return &session{getVersion: getVersion, getToken: getToken}
+}
+
+// get returns the cached version and token, acquiring new ones the first
+// time, after invalidate or once the token is older than tokenTTL.
+func (s *session) get(ctx context.Context) (string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != "" && time.Since(s.acquired) < tokenTTL {
+		return s.version, s.token, nil
+	}
+	version, err := s.getVersion(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	token, err := s.getToken(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	s.version, s.token, s.acquired = version, token, time.Now()
+	return version, token, nil
+}
+
+// invalidate drops token so the next search acquires a new one. Requests
+// that failed with an older token don't drop a newer one.
+func (s *session) invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token == token {
+		s.token = ""
+	}
+}
@@ -0,0 +1,322 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/xochilpili/subtitler-cli/internal/flags"
+	httpclient "github.com/xochilpili/subtitler-cli/internal/http-client"
+)
+
+// fakeSubdivx serves the home page, the token and the search endpoint of
+// subdivx, search answers the posted form of every search request and
+// download every other path.
+type fakeSubdivx struct {
+	search   func(w http.ResponseWriter, r *http.Request)
+	download func(w http.ResponseWriter, r *http.Request)
+
+	mu       sync.Mutex
+	tokens   int
+	searches int
+}
+
+func (f *fakeSubdivx) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/":
+		fmt.Fprint(w, `<html><div id="vs">v3.9.7</div></html>`)
+	case "/inc/gt.php":
+		f.mu.Lock()
+		f.tokens++
+		n := f.tokens
+		f.mu.Unlock()
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "cookie" + strconv.Itoa(n), Path: "/"})
+		json.NewEncoder(w).Encode(Token{Token: "token" + strconv.Itoa(n)})
+	case "/inc/ajax.php":
+		f.mu.Lock()
+		f.searches++
+		f.mu.Unlock()
+		f.search(w, r)
+	default:
+		if f.download == nil {
+			http.NotFound(w, r)
+			return
+		}
+		f.download(w, r)
+	}
+}
+
+// newTestSubdivx returns a provider talking to a fake subdivx.
+func newTestSubdivx(t *testing.T, fake *fakeSubdivx, settings flags.OptionFlags) *subdivx {
+	t.Helper()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	previous := baseUrl
+	baseUrl = server.URL + "/"
+	t.Cleanup(func() { baseUrl = previous })
+
+	settings.NoCache = true
+	settings.Comments = flags.CommentsNone
+	settings.Concurrency = 1
+	jar, _ := cookiejar.New(nil)
+	return NewSub(&settings, httpclient.New(false, jar))
+}
+
+// results answers a search with rows ids, echoing the page asked for.
+func results(w http.ResponseWriter, r *http.Request, total int, ids ...int) {
+	response := SubdivxResponse[SubData]{Secho: r.FormValue("sEcho"), ITotalRecords: 100000, ITotalDisplayRecords: total}
+	for _, id := range ids {
+		response.Data = append(response.Data, SubData{Id: id, Title: "Movie " + strconv.Itoa(id)})
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// ids returns first, first+1, ... n ids.
+func ids(first, n int) []int {
+	ids := make([]int, n)
+	for i := range ids {
+		ids[i] = first + i
+	}
+	return ids
+}
+
+func TestGetSubtitlesPaging(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings flags.OptionFlags
+		search   func(w http.ResponseWriter, r *http.Request)
+		want     int
+		searches int
+		wantErr  bool
+	}{
+		{
+			name:     "offset ignored",
+			settings: flags.OptionFlags{All: true},
+			search: func(w http.ResponseWriter, r *http.Request) {
+				results(w, r, 0, ids(1, subdivxPageSize)...)
+			},
+			want:     subdivxPageSize,
+			searches: 2,
+		},
+		{
+			name:     "short page",
+			settings: flags.OptionFlags{All: true},
+			search: func(w http.ResponseWriter, r *http.Request) {
+				results(w, r, 0, 1, 2)
+			},
+			want:     2,
+			searches: 1,
+		},
+		{
+			name:     "every page",
+			settings: flags.OptionFlags{All: true},
+			search: func(w http.ResponseWriter, r *http.Request) {
+				start, _ := strconv.Atoi(r.FormValue("iDisplayStart"))
+				results(w, r, 45, ids(start+1, min(subdivxPageSize, 45-start))...)
+			},
+			want:     45,
+			searches: 3,
+		},
+		{
+			name:     "page cap",
+			settings: flags.OptionFlags{All: true},
+			search: func(w http.ResponseWriter, r *http.Request) {
+				start, _ := strconv.Atoi(r.FormValue("iDisplayStart"))
+				results(w, r, 100000, ids(start+1, subdivxPageSize)...)
+			},
+			want:     subdivxMaxPages * subdivxPageSize,
+			searches: subdivxMaxPages,
+		},
+		{
+			name:     "limit",
+			settings: flags.OptionFlags{Limit: 30},
+			search: func(w http.ResponseWriter, r *http.Request) {
+				start, _ := strconv.Atoi(r.FormValue("iDisplayStart"))
+				results(w, r, 100, ids(start+1, subdivxPageSize)...)
+			},
+			want:     30,
+			searches: 2,
+		},
+		{
+			name:     "later page fails",
+			settings: flags.OptionFlags{All: true},
+			search: func(w http.ResponseWriter, r *http.Request) {
+				start, _ := strconv.Atoi(r.FormValue("iDisplayStart"))
+				if start > 0 {
+					http.Error(w, "boom", http.StatusInternalServerError)
+					return
+				}
+				results(w, r, 100, ids(1, subdivxPageSize)...)
+			},
+			want:     subdivxPageSize,
+			searches: 2,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeSubdivx{search: tt.search}
+			provider := newTestSubdivx(t, fake, tt.settings)
+			subtitles, err := provider.GetSubtitles(context.Background(), "movie")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetSubtitles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(subtitles) != tt.want {
+				t.Errorf("got %d subtitles, want %d", len(subtitles), tt.want)
+			}
+			if fake.searches != tt.searches {
+				t.Errorf("made %d searches, want %d", fake.searches, tt.searches)
+			}
+		})
+	}
+}
+
+func TestSearchPageTotal(t *testing.T) {
+	tests := []struct {
+		name  string
+		total int
+		rows  int
+		pages int
+	}{
+		{"counted", 45, subdivxPageSize, 3},
+		{"whole table only", 0, subdivxPageSize, 2},
+		{"short page", 0, 5, 1},
+		{"short page with a larger count", 500, 5, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeSubdivx{search: func(w http.ResponseWriter, r *http.Request) {
+				results(w, r, tt.total, ids(1, tt.rows)...)
+			}}
+			provider := newTestSubdivx(t, fake, flags.OptionFlags{})
+			page, err := provider.SearchPage(context.Background(), "movie", 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if page.Pages() != tt.pages {
+				t.Errorf("Pages() = %d, want %d", page.Pages(), tt.pages)
+			}
+		})
+	}
+}
+
+// staleToken answers a search the way subdivx rejects an expired token.
+func staleToken(w http.ResponseWriter) {
+	fmt.Fprint(w, `{"sEcho":"1","mensaje":"Token invalido, recarga la pagina"}`)
+}
+
+func TestSearchStaleToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		reject  func(w http.ResponseWriter)
+		rejects int
+		wantErr bool
+	}{
+		{
+			name:    "message once",
+			reject:  staleToken,
+			rejects: 1,
+		},
+		{
+			name:    "forbidden once",
+			reject:  func(w http.ResponseWriter) { w.WriteHeader(http.StatusForbidden) },
+			rejects: 1,
+		},
+		{
+			name:    "rejected twice",
+			reject:  staleToken,
+			rejects: 2,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent []string
+			fake := &fakeSubdivx{}
+			fake.search = func(w http.ResponseWriter, r *http.Request) {
+				cookie, _ := r.Cookie("session")
+				if cookie == nil {
+					t.Errorf("search %d sent no session cookie", fake.searches)
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				// the cookie set along with a token must come with it
+				token := r.FormValue("token")
+				if "token"+cookie.Value[len("cookie"):] != token {
+					t.Errorf("search sent %s with cookie %s", token, cookie.Value)
+				}
+				if r.FormValue("buscar397") != "movie" {
+					t.Errorf("search field buscar397 = %q", r.FormValue("buscar397"))
+				}
+				sent = append(sent, token)
+				if len(sent) <= tt.rejects {
+					tt.reject(w)
+					return
+				}
+				results(w, r, 1, 1)
+			}
+			provider := newTestSubdivx(t, fake, flags.OptionFlags{})
+			page, err := provider.SearchPage(context.Background(), "movie", 1)
+			if tt.wantErr {
+				if !errors.Is(err, errStaleToken) {
+					t.Fatalf("SearchPage() error = %v, want %v", err, errStaleToken)
+				}
+			} else if err != nil || len(page.Subtitles) != 1 {
+				t.Fatalf("SearchPage() = %v, %v", page, err)
+			}
+			// the retry acquires a new token instead of sending the old one
+			want := []string{"token1", "token2"}
+			if len(sent) != 2 || sent[0] != want[0] || sent[1] != want[1] {
+				t.Errorf("sent tokens %v, want %v", sent, want)
+			}
+		})
+	}
+}
+
+func TestFetchArchiveName(t *testing.T) {
+	zip := "PK\x03\x04rest"
+	tests := []struct {
+		name        string
+		contentType string
+		redirect    string
+		body        string
+		want        string
+	}{
+		{"content type", "application/zip", "", zip, "1.zip"},
+		{"redirect url", "application/octet-stream", "/sub9/1.rar", "Rar!\x1a\x07\x00", "1.rar"},
+		{"content", "application/octet-stream", "", zip, "1.zip"},
+		{"unknown", "application/octet-stream", "", "\x00\x01\x02", "1.bin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeSubdivx{download: func(w http.ResponseWriter, r *http.Request) {
+				if tt.redirect != "" && r.URL.Path == "/descargar.php" {
+					http.Redirect(w, r, tt.redirect, http.StatusFound)
+					return
+				}
+				w.Header().Set("Content-Type", tt.contentType)
+				fmt.Fprint(w, tt.body)
+			}}
+			provider := newTestSubdivx(t, fake, flags.OptionFlags{})
+			filename, err := provider.fetchArchive(context.Background(), 1, t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := filepath.Base(filename); got != tt.want {
+				t.Errorf("archive saved as %s, want %s", got, tt.want)
+			}
+			if _, err := os.Stat(filename); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package subtitle
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	assTimeRe     = regexp.MustCompile(`^(\d+):(\d{1,2}):(\d{1,2})\.(\d{1,3})$`)
+	assOverrideRe = regexp.MustCompile(`\{[^}]*\}`)
+	htmlTagRe     = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+)
+
+var defaultAssFields = []string{"Layer", "Start", "End", "Style", "Name", "MarginL", "MarginR", "MarginV", "Effect", "Text"}
+
+func parseASS(text string) (*Subtitle, error) {
+	sub := &Subtitle{}
+	fields := defaultAssFields
+	inEvents := false
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") {
+			inEvents = strings.EqualFold(line, "[Events]")
+			continue
+		}
+		if !inEvents {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Format":
+			fields = strings.Split(value, ",")
+			for i := range fields {
+				fields[i] = strings.TrimSpace(fields[i])
+			}
+		case "Dialogue":
+			values := strings.SplitN(strings.TrimSpace(value), ",", len(fields))
+			if len(values) < len(fields) {
+				continue
+			}
+			var cue Cue
+			for i, field := range fields {
+				switch field {
+				case "Start":
+					cue.Start = assDuration(values[i])
+				case "End":
+					cue.End = assDuration(values[i])
+				case "Text":
+					cue.Text = assToText(values[i])
+				}
+			}
+			sub.Cues = append(sub.Cues, cue)
+		}
+	}
+	if len(sub.Cues) < 1 {
+		return nil, fmt.Errorf("no dialogue lines found")
+	}
+	return sub, nil
+}
+
+func writeASS(w io.Writer, sub *Subtitle, format Format) error {
+	var header string
+	if format == SSA {
+		header = "[Script Info]\nScriptType: v4.00\nCollisions: Normal\n\n" +
+			"[V4 Styles]\nFormat: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, TertiaryColour, BackColour, Bold, Italic, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, AlphaLevel, Encoding\n" +
+			"Style: Default,Arial,20,16777215,65535,65535,0,0,0,1,2,2,2,10,10,10,0,1\n\n" +
+			"[Events]\nFormat: Marked, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n"
+	} else {
+		header = "[Script Info]\nScriptType: v4.00+\nCollisions: Normal\nPlayResX: 384\nPlayResY: 288\n\n" +
+			"[V4+ Styles]\nFormat: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding\n" +
+			"Style: Default,Arial,20,&H00FFFFFF,&H0000FFFF,&H00000000,&H00000000,0,0,0,0,100,100,0,0,1,2,2,2,10,10,10,1\n\n" +
+			"[Events]\nFormat: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\n"
+	}
+	if _, err := io.WriteString(w, header); err != nil {
+		return err
+	}
+	marked := "0"
+	if format == SSA {
+		marked = "Marked=0"
+	}
+	for _, cue := range sub.Cues {
+		_, err := fmt.Fprintf(w, "Dialogue: %s,%s,%s,Default,,0,0,0,,%s\n", marked, assClock(cue.Start), assClock(cue.End), textToAss(cue.Text))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func assDuration(value string) time.Duration {
+	match := assTimeRe.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return 0
+	}
+	return clock(match[1], match[2], match[3], match[4])
+}
+
+func assClock(d time.Duration) string {
+	h, m, s, ms := splitClock(d)
+	return fmt.Sprintf("%d:%02d:%02d.%02d", h, m, s, ms/10)
+}
+
+func assToText(text string) string {
+	replacer := strings.NewReplacer(`\N`, "\n", `\n`, "\n", `\h`, " ",
+		`{\i1}`, "<i>", `{\i0}`, "</i>", `{\b1}`, "<b>", `{\b0}`, "</b>", `{\u1}`, "<u>", `{\u0}`, "</u>")
+	return assOverrideRe.ReplaceAllString(replacer.Replace(text), "")
+}
+
+func textToAss(text string) string {
+	replacer := strings.NewReplacer("\n", `\N`,
+		"<i>", `{\i1}`, "</i>", `{\i0}`, "<b>", `{\b1}`, "</b>", `{\b0}`, "<u>", `{\u1}`, "</u>", `{\u0}`)
+	return replacer.Replace(htmlTagRe.ReplaceAllStringFunc(text, keepBasicTags))
+}
+
+// keepBasicTags drops every html tag except the styling ones shared by all
+// the supported formats.
+func keepBasicTags(tag string) string {
+	switch strings.ToLower(tag) {
+	case "<i>", "</i>", "<b>", "</b>", "<u>", "</u>":
+		return strings.ToLower(tag)
+	}
+	return ""
+}
@@ -0,0 +1,99 @@
+package subtitle
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	microDVDLineRe = regexp.MustCompile(`^\{(\d+)\}\{(\d*)\}(.*)$`)
+	microDVDTagRe  = regexp.MustCompile(`\{[yY]:([ibu]+)\}`)
+	microDVDCtrlRe = regexp.MustCompile(`\{[a-zA-Z]:[^}]*\}`)
+)
+
+func parseMicroDVD(text string, fps float64) (*Subtitle, error) {
+	if fps <= 0 {
+		fps = DefaultFPS
+	}
+	sub := &Subtitle{}
+	for i, line := range strings.Split(text, "\n") {
+		match := microDVDLineRe.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+		start, _ := strconv.Atoi(match[1])
+		end, _ := strconv.Atoi(match[2])
+		// a first line like {1}{1}23.976 declares the frame rate of the file
+		if i == 0 && start <= 1 && end <= 1 {
+			if declared, err := strconv.ParseFloat(strings.TrimSpace(match[3]), 64); err == nil && declared > 0 {
+				fps = declared
+				continue
+			}
+		}
+		sub.Cues = append(sub.Cues, Cue{
+			Start: frameToDuration(start, fps),
+			End:   frameToDuration(end, fps),
+			Text:  microDVDToText(match[3]),
+		})
+	}
+	if len(sub.Cues) < 1 {
+		return nil, fmt.Errorf("no microdvd lines found")
+	}
+	return sub, nil
+}
+
+func writeMicroDVD(w io.Writer, sub *Subtitle, fps float64) error {
+	if fps <= 0 {
+		fps = DefaultFPS
+	}
+	if _, err := fmt.Fprintf(w, "{1}{1}%s\n", strconv.FormatFloat(fps, 'f', -1, 64)); err != nil {
+		return err
+	}
+	for _, cue := range sub.Cues {
+		_, err := fmt.Fprintf(w, "{%d}{%d}%s\n", durationToFrame(cue.Start, fps), durationToFrame(cue.End, fps), textToMicroDVD(cue.Text))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func frameToDuration(frame int, fps float64) time.Duration {
+	return time.Duration(float64(frame) / fps * float64(time.Second))
+}
+
+func durationToFrame(d time.Duration, fps float64) int {
+	return int(math.Round(d.Seconds() * fps))
+}
+
+func microDVDToText(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "|") {
+		// {y:i} at the start of a line styles the whole line
+		if match := microDVDTagRe.FindStringSubmatch(line); match != nil {
+			line = microDVDTagRe.ReplaceAllString(line, "")
+			for _, tag := range match[1] {
+				line = fmt.Sprintf("<%c>%s</%c>", tag, line, tag)
+			}
+		}
+		lines = append(lines, microDVDCtrlRe.ReplaceAllString(line, ""))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func textToMicroDVD(text string) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		plain := htmlTagRe.ReplaceAllString(line, "")
+		if strings.HasPrefix(strings.ToLower(line), "<i>") {
+			plain = "{y:i}" + plain
+		}
+		lines = append(lines, plain)
+	}
+	return strings.Join(lines, "|")
+}
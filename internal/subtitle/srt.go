@@ -0,0 +1,67 @@
+package subtitle
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var srtTimeRe = regexp.MustCompile(`(\d+):(\d{1,2}):(\d{1,2})[,.](\d{1,3})\s*-->\s*(\d+):(\d{1,2}):(\d{1,2})[,.](\d{1,3})`)
+
+func parseSRT(text string) (*Subtitle, error) {
+	sub := &Subtitle{}
+	for _, block := range splitBlocks(text) {
+		lines := strings.Split(block, "\n")
+		// the index line is optional, look for the timing line
+		for i, line := range lines {
+			match := srtTimeRe.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			sub.Cues = append(sub.Cues, Cue{
+				Start: clock(match[1], match[2], match[3], match[4]),
+				End:   clock(match[5], match[6], match[7], match[8]),
+				Text:  strings.Join(lines[i+1:], "\n"),
+			})
+			break
+		}
+	}
+	if len(sub.Cues) < 1 {
+		return nil, fmt.Errorf("no srt cues found")
+	}
+	return sub, nil
+}
+
+func writeSRT(w io.Writer, sub *Subtitle) error {
+	for i, cue := range sub.Cues {
+		_, err := fmt.Fprintf(w, "%d\n%s --> %s\n%s\n\n", i+1, srtClock(cue.Start), srtClock(cue.End), cue.Text)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func srtClock(d time.Duration) string {
+	h, m, s, ms := splitClock(d)
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", h, m, s, ms)
+}
+
+// clock builds a duration from hours, minutes, seconds and a fraction of a
+// second, the fraction keeps its decimal meaning (".5" is 500ms).
+func clock(h, m, s, fraction string) time.Duration {
+	hours, _ := strconv.Atoi(h)
+	minutes, _ := strconv.Atoi(m)
+	seconds, _ := strconv.Atoi(s)
+	for len(fraction) < 3 {
+		fraction += "0"
+	}
+	millis, _ := strconv.Atoi(fraction[:3])
+	return time.Duration(hours)*time.Hour +
+		time.Duration(minutes)*time.Minute +
+		time.Duration(seconds)*time.Second +
+		time.Duration(millis)*time.Millisecond
+}
@@ -0,0 +1,191 @@
+package subtitle
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Format string
+
+const (
+	SRT      Format = "srt"
+	ASS      Format = "ass"
+	SSA      Format = "ssa"
+	VTT      Format = "vtt"
+	MicroDVD Format = "sub"
+)
+
+// DefaultFPS is the frame rate used for MicroDVD files that don't declare one.
+const DefaultFPS = 23.976
+
+var ErrUnknownFormat = errors.New("unknown subtitle format")
+
+// Cue is a single subtitle line shown between Start and End. Text keeps
+// one line per "\n" and uses <i>, <b> and <u> for styling whatever the
+// source format is.
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+type Subtitle struct {
+	Cues []Cue
+}
+
+// ParseFormat returns the format for a name or extension like "vtt" or ".vtt".
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(strings.TrimPrefix(name, ".")) {
+	case "srt":
+		return SRT, nil
+	case "ass":
+		return ASS, nil
+	case "ssa":
+		return SSA, nil
+	case "vtt", "webvtt":
+		return VTT, nil
+	case "sub", "microdvd":
+		return MicroDVD, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownFormat, name)
+}
+
+// Detect guesses the format of data, falling back to the file extension.
+func Detect(filename string, data []byte) (Format, error) {
+	content := bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+	switch {
+	case bytes.HasPrefix(content, []byte("WEBVTT")):
+		return VTT, nil
+	case bytes.HasPrefix(content, []byte("[Script Info]")):
+		if bytes.Contains(content, []byte("[V4+ Styles]")) {
+			return ASS, nil
+		}
+		return SSA, nil
+	case microDVDLineRe.Match(firstLine(content)):
+		return MicroDVD, nil
+	case srtTimeRe.Match(content):
+		return SRT, nil
+	}
+	return ParseFormat(filepath.Ext(filename))
+}
+
+// Parse reads a subtitle of the given format, fps is only used by MicroDVD.
+func Parse(r io.Reader, format Format, fps float64) (*Subtitle, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+
+	switch format {
+	case SRT:
+		return parseSRT(text)
+	case ASS, SSA:
+		return parseASS(text)
+	case VTT:
+		return parseVTT(text)
+	case MicroDVD:
+		return parseMicroDVD(text, fps)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+}
+
+// Write encodes the subtitle in the given format, fps is only used by MicroDVD.
+func (s *Subtitle) Write(w io.Writer, format Format, fps float64) error {
+	bw := bufio.NewWriter(w)
+	var err error
+	switch format {
+	case SRT:
+		err = writeSRT(bw, s)
+	case ASS, SSA:
+		err = writeASS(bw, s, format)
+	case VTT:
+		err = writeVTT(bw, s)
+	case MicroDVD:
+		err = writeMicroDVD(bw, s, fps)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// ReadFile parses a subtitle file detecting its format.
+func ReadFile(path string, fps float64) (*Subtitle, Format, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	format, err := Detect(path, data)
+	if err != nil {
+		return nil, "", err
+	}
+	sub, err := Parse(bytes.NewReader(data), format, fps)
+	return sub, format, err
+}
+
+// WriteFile writes the subtitle to path in the given format.
+func (s *Subtitle) WriteFile(path string, format Format, fps float64) error {
+	var buf bytes.Buffer
+	if err := s.Write(&buf, format, fps); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// ConvertFile converts the subtitle at path to format, the converted file
+// replaces the source one and its path is returned.
+func ConvertFile(path string, format Format, fps float64) (string, error) {
+	sub, source, err := ReadFile(path, fps)
+	if err != nil {
+		return "", err
+	}
+	if source == format {
+		return path, nil
+	}
+	target := strings.TrimSuffix(path, filepath.Ext(path)) + "." + string(format)
+	if err := sub.WriteFile(target, format, fps); err != nil {
+		return "", err
+	}
+	if target != path {
+		if err := os.Remove(path); err != nil {
+			return "", err
+		}
+	}
+	return target, nil
+}
+
+func firstLine(data []byte) []byte {
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		return data[:i]
+	}
+	return data
+}
+
+func splitBlocks(text string) []string {
+	var blocks []string
+	for _, block := range strings.Split(text, "\n\n") {
+		if strings.TrimSpace(block) != "" {
+			blocks = append(blocks, strings.Trim(block, "\n"))
+		}
+	}
+	return blocks
+}
+
+func splitClock(d time.Duration) (h, m, s, ms int) {
+	if d < 0 {
+		d = 0
+	}
+	total := int(d / time.Millisecond)
+	return total / 3600000, total / 60000 % 60, total / 1000 % 60, total % 1000
+}
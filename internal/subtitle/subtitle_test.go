@@ -0,0 +1,156 @@
+package subtitle
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sample only uses what every format keeps: centisecond times on whole
+// frames at 25 fps and italic for whole lines.
+var sample = &Subtitle{Cues: []Cue{
+	{Start: time.Second, End: 2*time.Second + 400*time.Millisecond, Text: "Hola"},
+	{Start: 3 * time.Second, End: 4*time.Second + 200*time.Millisecond, Text: "¿Qué tal?\n<i>Bien, gracias</i>"},
+	{Start: time.Hour + 2*time.Minute + 3*time.Second, End: time.Hour + 2*time.Minute + 5*time.Second, Text: "Adiós"},
+}}
+
+var formats = []Format{SRT, VTT, ASS, SSA, MicroDVD}
+
+func TestRoundTrip(t *testing.T) {
+	for _, from := range formats {
+		for _, to := range formats {
+			t.Run(string(from)+"-"+string(to), func(t *testing.T) {
+				var buf bytes.Buffer
+				if err := sample.Write(&buf, from, 25); err != nil {
+					t.Fatal(err)
+				}
+				detected, err := Detect("subtitle.txt", buf.Bytes())
+				if err != nil || detected != from {
+					t.Fatalf("Detect() = %v, %v, want %v", detected, err, from)
+				}
+				sub, err := Parse(&buf, from, 25)
+				if err != nil {
+					t.Fatal(err)
+				}
+				buf.Reset()
+				if err := sub.Write(&buf, to, 25); err != nil {
+					t.Fatal(err)
+				}
+				got, err := Parse(&buf, to, 25)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(got, sample) {
+					t.Errorf("got %+v, want %+v", got.Cues, sample.Cues)
+				}
+			})
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		fps    float64
+		input  string
+		want   []Cue
+	}{
+		{
+			name:   "srt with bom and crlf",
+			format: SRT,
+			input:  "\xef\xbb\xbf1\r\n00:00:01,500 --> 00:00:02,000\r\nHola\r\nmundo\r\n\r\n2\r\n00:00:03,000 --> 00:00:04,000\r\nAdiós\r\n",
+			want: []Cue{
+				{Start: 1500 * time.Millisecond, End: 2 * time.Second, Text: "Hola\nmundo"},
+				{Start: 3 * time.Second, End: 4 * time.Second, Text: "Adiós"},
+			},
+		},
+		{
+			name:   "srt without index and short fraction",
+			format: SRT,
+			input:  "00:00:01.5 --> 00:00:02.25\nHola\n",
+			want:   []Cue{{Start: 1500 * time.Millisecond, End: 2250 * time.Millisecond, Text: "Hola"}},
+		},
+		{
+			name:   "vtt without hours and with note",
+			format: VTT,
+			input:  "WEBVTT\n\nNOTE a comment\n\nintro\n01:02.500 --> 01:03.000 align:start\nHola\n",
+			want:   []Cue{{Start: time.Minute + 2500*time.Millisecond, End: time.Minute + 3*time.Second, Text: "Hola"}},
+		},
+		{
+			name:   "ass with own field order and overrides",
+			format: ASS,
+			input:  "[Script Info]\nTitle: x\n\n[Events]\nFormat: Start, End, Text\nDialogue: 0:00:01.50,0:00:02.00,{\\an8}{\\b1}Hola{\\b0}\\N{\\i1}mundo, sí{\\i0}\n",
+			want:   []Cue{{Start: 1500 * time.Millisecond, End: 2 * time.Second, Text: "<b>Hola</b>\n<i>mundo, sí</i>"}},
+		},
+		{
+			name:   "microdvd fps header",
+			format: MicroDVD,
+			fps:    DefaultFPS,
+			input:  "{1}{1}25\n{25}{50}Hola|{y:i}mundo\n",
+			want:   []Cue{{Start: time.Second, End: 2 * time.Second, Text: "Hola\n<i>mundo</i>"}},
+		},
+		{
+			name:   "microdvd without header",
+			format: MicroDVD,
+			fps:    10,
+			input:  "{10}{25}{c:$0000ff}Hola\n",
+			want:   []Cue{{Start: time.Second, End: 2500 * time.Millisecond, Text: "Hola"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.input), tt.format, tt.fps)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Cues, tt.want) {
+				t.Errorf("got %+v, want %+v", got.Cues, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteMicroDVDHeader(t *testing.T) {
+	var buf bytes.Buffer
+	sub := &Subtitle{Cues: []Cue{{Start: time.Second, End: 2 * time.Second, Text: "<i>Hola</i>\nmundo"}}}
+	if err := sub.Write(&buf, MicroDVD, 25); err != nil {
+		t.Fatal(err)
+	}
+	if want := "{1}{1}25\n{25}{50}{y:i}Hola|mundo\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+	// the header wins over the frame rate given when reading
+	got, err := Parse(&buf, MicroDVD, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, sub) {
+		t.Errorf("got %+v, want %+v", got.Cues, sub.Cues)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		filename string
+		data     string
+		want     Format
+		wantErr  bool
+	}{
+		{"a.txt", "\xef\xbb\xbfWEBVTT\n\n", VTT, false},
+		{"a.txt", "[Script Info]\n\n[V4+ Styles]\n", ASS, false},
+		{"a.txt", "[Script Info]\n\n[V4 Styles]\n", SSA, false},
+		{"a.txt", "{1}{1}23.976\n", MicroDVD, false},
+		{"a.txt", "\n1\n00:00:01,000 --> 00:00:02,000\n", SRT, false},
+		{"a.srt", "", SRT, false},
+		{"a.txt", "hola", "", true},
+	}
+	for _, tt := range tests {
+		got, err := Detect(tt.filename, []byte(tt.data))
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("Detect(%q, %q) = %v, %v, want %v", tt.filename, tt.data, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package subtitle
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	offsetRe    = regexp.MustCompile(`^([+-])?(\d+(?:\.\d+)?)(ms|s|m)?$`)
+	timestampRe = regexp.MustCompile(`^([+-])?(?:(\d+):)?(\d{1,2}):(\d{1,2})(?:[,.](\d{1,3}))?$`)
+)
+
+// Anchor maps the time a line is shown at (From) to the time it should be
+// shown at (To).
+type Anchor struct {
+	From time.Duration
+	To   time.Duration
+}
+
+// SyncOptions are applied in order: frame rate conversion, linear stretch
+// between two anchors and a constant offset.
+type SyncOptions struct {
+	Offset  time.Duration
+	Anchors []Anchor
+	FromFPS float64
+	ToFPS   float64
+}
+
+func (s *Subtitle) Sync(opts SyncOptions) error {
+	if opts.FromFPS > 0 && opts.ToFPS > 0 {
+		s.ConvertFPS(opts.FromFPS, opts.ToFPS)
+	}
+	switch len(opts.Anchors) {
+	case 0:
+	case 2:
+		if err := s.Stretch(opts.Anchors[0], opts.Anchors[1]); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("stretch needs exactly two anchors, got %d", len(opts.Anchors))
+	}
+	if opts.Offset != 0 {
+		s.Shift(opts.Offset)
+	}
+	return nil
+}
+
+// Shift moves every cue by offset, cues never start before zero.
+func (s *Subtitle) Shift(offset time.Duration) {
+	s.transform(func(d time.Duration) time.Duration {
+		return d + offset
+	})
+}
+
+// Stretch linearly maps every cue so both anchors land on their target time,
+// it fixes subtitles that drift as the video plays.
+func (s *Subtitle) Stretch(first Anchor, second Anchor) error {
+	if first.From == second.From {
+		return errors.New("anchors must be at different times")
+	}
+	scale := float64(second.To-first.To) / float64(second.From-first.From)
+	if scale <= 0 {
+		return errors.New("anchors must keep the order of the cues")
+	}
+	s.transform(func(d time.Duration) time.Duration {
+		return first.To + time.Duration(float64(d-first.From)*scale)
+	})
+	return nil
+}
+
+// ConvertFPS retimes a subtitle made for a video at from frames per second
+// to a video at to, e.g. 23.976 -> 25.
+func (s *Subtitle) ConvertFPS(from float64, to float64) {
+	scale := from / to
+	s.transform(func(d time.Duration) time.Duration {
+		return time.Duration(float64(d) * scale)
+	})
+}
+
+func (s *Subtitle) transform(fn func(time.Duration) time.Duration) {
+	for i := range s.Cues {
+		s.Cues[i].Start = max(fn(s.Cues[i].Start), 0)
+		s.Cues[i].End = max(fn(s.Cues[i].End), 0)
+	}
+}
+
+// ParseOffset reads offsets like "+1.5s", "-500ms", "2m", "1.5" (seconds)
+// or a signed timestamp like "-00:00:01,500".
+func ParseOffset(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if match := offsetRe.FindStringSubmatch(value); match != nil {
+		amount, err := strconv.ParseFloat(match[2], 64)
+		if err != nil {
+			return 0, err
+		}
+		unit := time.Second
+		switch match[3] {
+		case "ms":
+			unit = time.Millisecond
+		case "m":
+			unit = time.Minute
+		}
+		offset := time.Duration(amount * float64(unit))
+		if match[1] == "-" {
+			offset = -offset
+		}
+		return offset, nil
+	}
+	return ParseTimestamp(value)
+}
+
+// ParseTimestamp reads a [hh:]mm:ss[,mmm] timestamp, an optional sign is kept.
+func ParseTimestamp(value string) (time.Duration, error) {
+	match := timestampRe.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	d := clock(orZero(match[2]), match[3], match[4], match[5])
+	if match[1] == "-" {
+		d = -d
+	}
+	return d, nil
+}
+
+// ParseAnchor reads an anchor written as "from=to", e.g. "00:01:00,000=00:01:02,500".
+func ParseAnchor(value string) (Anchor, error) {
+	from, to, ok := strings.Cut(value, "=")
+	if !ok {
+		return Anchor{}, fmt.Errorf("invalid anchor %q, expected from=to", value)
+	}
+	fromTime, err := ParseOffset(from)
+	if err != nil {
+		return Anchor{}, err
+	}
+	toTime, err := ParseOffset(to)
+	if err != nil {
+		return Anchor{}, err
+	}
+	return Anchor{From: fromTime, To: toTime}, nil
+}
@@ -0,0 +1,121 @@
+package subtitle
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseOffset(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"1.5", 1500 * time.Millisecond, false},
+		{"+1.5s", 1500 * time.Millisecond, false},
+		{"-500ms", -500 * time.Millisecond, false},
+		{"2m", 2 * time.Minute, false},
+		{"-00:00:01,500", -1500 * time.Millisecond, false},
+		{"01:02:03.4", time.Hour + 2*time.Minute + 3400*time.Millisecond, false},
+		{"02:03", 2*time.Minute + 3*time.Second, false},
+		{"1h", 0, true},
+		{"abc", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseOffset(tt.value)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseOffset(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseAnchor(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Anchor
+		wantErr bool
+	}{
+		{"00:01:00,000=00:01:02,500", Anchor{From: time.Minute, To: time.Minute + 2500*time.Millisecond}, false},
+		{"60=62.5s", Anchor{From: time.Minute, To: time.Minute + 2500*time.Millisecond}, false},
+		{"00:01:00,000", Anchor{}, true},
+		{"x=1", Anchor{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAnchor(tt.value)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseAnchor(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestSync(t *testing.T) {
+	cues := func(times ...time.Duration) *Subtitle {
+		sub := &Subtitle{}
+		for i := 0; i < len(times); i += 2 {
+			sub.Cues = append(sub.Cues, Cue{Start: times[i], End: times[i+1]})
+		}
+		return sub
+	}
+	tests := []struct {
+		name    string
+		sub     *Subtitle
+		opts    SyncOptions
+		want    *Subtitle
+		wantErr bool
+	}{
+		{
+			name: "offset",
+			sub:  cues(time.Second, 2*time.Second),
+			opts: SyncOptions{Offset: 1500 * time.Millisecond},
+			want: cues(2500*time.Millisecond, 3500*time.Millisecond),
+		},
+		{
+			name: "negative offset stops at zero",
+			sub:  cues(time.Second, 3*time.Second),
+			opts: SyncOptions{Offset: -2 * time.Second},
+			want: cues(0, time.Second),
+		},
+		{
+			name: "stretch",
+			sub:  cues(10*time.Second, 20*time.Second, 100*time.Second, 110*time.Second),
+			opts: SyncOptions{Anchors: []Anchor{{From: 10 * time.Second, To: 12 * time.Second}, {From: 110 * time.Second, To: 122 * time.Second}}},
+			want: cues(12*time.Second, 23*time.Second, 111*time.Second, 122*time.Second),
+		},
+		{
+			name: "frame rate then offset",
+			sub:  cues(25*time.Second, 50*time.Second),
+			opts: SyncOptions{FromFPS: 25, ToFPS: 50, Offset: time.Second},
+			want: cues(13500*time.Millisecond, 26*time.Second),
+		},
+		{
+			name:    "one anchor",
+			sub:     cues(time.Second, 2*time.Second),
+			opts:    SyncOptions{Anchors: []Anchor{{From: time.Second, To: 2 * time.Second}}},
+			wantErr: true,
+		},
+		{
+			name:    "anchors at the same time",
+			sub:     cues(time.Second, 2*time.Second),
+			opts:    SyncOptions{Anchors: []Anchor{{From: time.Second, To: time.Second}, {From: time.Second, To: 2 * time.Second}}},
+			wantErr: true,
+		},
+		{
+			name:    "anchors reversing the cues",
+			sub:     cues(time.Second, 2*time.Second),
+			opts:    SyncOptions{Anchors: []Anchor{{From: time.Second, To: 5 * time.Second}, {From: 5 * time.Second, To: time.Second}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.sub.Sync(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Sync() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(tt.sub, tt.want) {
+				t.Errorf("got %+v, want %+v", tt.sub.Cues, tt.want.Cues)
+			}
+		})
+	}
+}
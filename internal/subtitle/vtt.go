@@ -0,0 +1,68 @@
+package subtitle
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var vttTimeRe = regexp.MustCompile(`(?:(\d+):)?(\d{2}):(\d{2})\.(\d{3})\s+-->\s+(?:(\d+):)?(\d{2}):(\d{2})\.(\d{3})`)
+
+func parseVTT(text string) (*Subtitle, error) {
+	if !strings.HasPrefix(strings.TrimSpace(text), "WEBVTT") {
+		return nil, fmt.Errorf("missing WEBVTT header")
+	}
+	sub := &Subtitle{}
+	for _, block := range splitBlocks(text) {
+		if strings.HasPrefix(block, "WEBVTT") || strings.HasPrefix(block, "NOTE") ||
+			strings.HasPrefix(block, "STYLE") || strings.HasPrefix(block, "REGION") {
+			continue
+		}
+		lines := strings.Split(block, "\n")
+		for i, line := range lines {
+			match := vttTimeRe.FindStringSubmatch(line)
+			if match == nil {
+				continue
+			}
+			sub.Cues = append(sub.Cues, Cue{
+				Start: clock(orZero(match[1]), match[2], match[3], match[4]),
+				End:   clock(orZero(match[5]), match[6], match[7], match[8]),
+				Text:  strings.Join(lines[i+1:], "\n"),
+			})
+			break
+		}
+	}
+	if len(sub.Cues) < 1 {
+		return nil, fmt.Errorf("no vtt cues found")
+	}
+	return sub, nil
+}
+
+func writeVTT(w io.Writer, sub *Subtitle) error {
+	if _, err := io.WriteString(w, "WEBVTT\n\n"); err != nil {
+		return err
+	}
+	for _, cue := range sub.Cues {
+		// "-->" is not allowed inside a cue payload
+		text := strings.ReplaceAll(cue.Text, "-->", "->")
+		_, err := fmt.Fprintf(w, "%s --> %s\n%s\n\n", vttClock(cue.Start), vttClock(cue.End), text)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func vttClock(d time.Duration) string {
+	h, m, s, ms := splitClock(d)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", h, m, s, ms)
+}
+
+func orZero(s string) string {
+	if s == "" {
+		return "0"
+	}
+	return s
+}
@@ -0,0 +1,101 @@
+package tui
+
+import "unicode/utf8"
+
+type key int
+
+const (
+	keyRune key = iota
+	keyUp
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyHome
+	keyEnd
+	keyEnter
+	keyEsc
+	keyBackspace
+	keyTab
+	keyCtrlC
+	keyCtrlU
+)
+
+type keyPress struct {
+	key  key
+	char rune
+}
+
+var escapeKeys = map[string]key{
+	"\x1b[A":  keyUp,
+	"\x1bOA":  keyUp,
+	"\x1b[B":  keyDown,
+	"\x1bOB":  keyDown,
+	"\x1b[5~": keyPageUp,
+	"\x1b[6~": keyPageDown,
+	"\x1b[H":  keyHome,
+	"\x1bOH":  keyHome,
+	"\x1b[1~": keyHome,
+	"\x1b[F":  keyEnd,
+	"\x1bOF":  keyEnd,
+	"\x1b[4~": keyEnd,
+}
+
+// decodeKeys splits the bytes read from a raw terminal into key presses,
+// unknown escape sequences are dropped.
+func decodeKeys(data []byte) []keyPress {
+	var keys []keyPress
+	for len(data) > 0 {
+		if data[0] == 0x1b {
+			if len(data) == 1 {
+				keys = append(keys, keyPress{key: keyEsc})
+				break
+			}
+			matched := false
+			for seq, k := range escapeKeys {
+				if len(data) >= len(seq) && string(data[:len(seq)]) == seq {
+					keys = append(keys, keyPress{key: k})
+					data = data[len(seq):]
+					matched = true
+					break
+				}
+			}
+			if matched {
+				continue
+			}
+			if data[1] != '[' && data[1] != 'O' {
+				keys = append(keys, keyPress{key: keyEsc})
+				data = data[1:]
+				continue
+			}
+			// skip an unknown CSI sequence up to its final byte
+			i := 2
+			for i < len(data) && (data[i] < 0x40 || data[i] > 0x7e) {
+				i++
+			}
+			data = data[min(i+1, len(data)):]
+			continue
+		}
+
+		switch data[0] {
+		case '\r', '\n':
+			keys = append(keys, keyPress{key: keyEnter})
+		case 0x7f, 0x08:
+			keys = append(keys, keyPress{key: keyBackspace})
+		case '\t':
+			keys = append(keys, keyPress{key: keyTab})
+		case 0x03:
+			keys = append(keys, keyPress{key: keyCtrlC})
+		case 0x15:
+			keys = append(keys, keyPress{key: keyCtrlU})
+		default:
+			r, size := utf8.DecodeRune(data)
+			if r >= 0x20 {
+				keys = append(keys, keyPress{key: keyRune, char: r})
+			}
+			data = data[size:]
+			continue
+		}
+		data = data[1:]
+	}
+	return keys
+}
@@ -0,0 +1,156 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+const (
+	enterScreen = "\x1b[?1049h\x1b[?25l"
+	leaveScreen = "\x1b[?25h\x1b[?1049l"
+	clearLine   = "\x1b[K"
+	reverse     = "\x1b[7m"
+	bold        = "\x1b[1m"
+	dim         = "\x1b[2m"
+	reset       = "\x1b[0m"
+)
+
+// listHeight is the number of result rows, the rest of the screen is used
+// by the header, the detail pane and the status lines.
+func (t *TUI) listHeight() int {
+	if t.mode == modeDetail {
+		return 0
+	}
+	return max((t.height-4)/2, 3)
+}
+
+func (t *TUI) render() {
+	var out strings.Builder
+	out.WriteString("\x1b[H")
+	lines := t.header()
+	if t.mode == modeDetail {
+		lines = append(lines, t.detailLines(t.height-len(lines)-2, true)...)
+	} else {
+		lines = append(lines, t.listLines()...)
+		lines = append(lines, strings.Repeat("─", t.width))
+		lines = append(lines, t.detailLines(t.height-len(lines)-2, false)...)
+	}
+	// the status and help lines always show, even on a tiny terminal
+	body := max(t.height-2, 0)
+	for len(lines) < body {
+		lines = append(lines, "")
+	}
+	lines = append(lines[:body], t.statusLine(), t.helpLine())
+
+	for i, line := range lines {
+		out.WriteString(text.Snip(line, t.width, "…"))
+		out.WriteString(reset + clearLine)
+		if i < len(lines)-1 {
+			out.WriteString("\r\n")
+		}
+	}
+	fmt.Fprint(os.Stdout, out.String())
+}
+
+func (t *TUI) header() []string {
+	title := fmt.Sprintf("%ssubtitler%s  %s  %d/%d results", bold, reset, t.settings.Title, len(t.visible), len(t.subtitles))
+	filter := "filter: " + t.filter
+	if t.mode == modeFilter {
+		filter = bold + "filter: " + t.filter + "█" + reset
+	}
+	return []string{title, filter}
+}
+
+func (t *TUI) listLines() []string {
+	height := t.listHeight()
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	}
+	if t.cursor >= t.offset+height {
+		t.offset = t.cursor - height + 1
+	}
+
+	var lines []string
+	for row := t.offset; row < len(t.visible) && row < t.offset+height; row++ {
+		item := t.subtitles[t.visible[row]]
+		line := fmt.Sprintf("%3d %8d %6d↓ %2dcd  %s", t.visible[row], item.Id, item.Downloads, item.Cds,
+			t.highlighter.Highlight(item.Title))
+		if row == t.cursor {
+			// drop the highlight so the reversed row stays readable
+			line = reverse + text.Pad(fmt.Sprintf("%3d %8d %6d↓ %2dcd  %s", t.visible[row], item.Id, item.Downloads, item.Cds, item.Title), t.width, ' ')
+		}
+		lines = append(lines, line)
+	}
+	if len(t.visible) == 0 {
+		lines = append(lines, dim+"no results match the filter"+reset)
+	}
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+// detailLines renders the selected subtitle with its description, comments
+// and preview, scrolled when it is the only pane on screen.
+func (t *TUI) detailLines(height int, scroll bool) []string {
+	selected, ok := t.selected()
+	if !ok || height < 1 {
+		return nil
+	}
+	wrap := func(s string) []string {
+		return strings.Split(text.WrapSoft(s, max(t.width-2, 10)), "\n")
+	}
+
+	var lines []string
+	lines = append(lines, bold+fmt.Sprintf("%d  %s", selected.Id, selected.Title)+reset)
+	lines = append(lines, wrap(t.highlighter.Highlight(selected.Description))...)
+	lines = append(lines, "")
+	switch {
+	case selected.Comments == nil:
+		lines = append(lines, dim+"comments not loaded, press tab to open the details"+reset)
+	case len(*selected.Comments) == 0:
+		lines = append(lines, dim+"no comments"+reset)
+	default:
+		lines = append(lines, bold+fmt.Sprintf("comments (%d)", len(*selected.Comments))+reset)
+		for _, comment := range *selected.Comments {
+			lines = append(lines, wrap(fmt.Sprintf("%s%s:%s %s", bold, comment.Nick, reset,
+				t.highlighter.Highlight(comment.Comment)))...)
+		}
+	}
+	if cues, ok := t.preview[selected.Id]; ok {
+		lines = append(lines, "", bold+"preview"+reset)
+		for _, cue := range cues {
+			lines = append(lines, fmt.Sprintf("%s%v%s  %s", dim, cue.Start.Truncate(1e6), reset,
+				strings.ReplaceAll(cue.Text, "\n", " / ")))
+		}
+	}
+
+	if scroll {
+		t.detail = min(t.detail, max(len(lines)-height, 0))
+		lines = lines[t.detail:]
+	}
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	return lines
+}
+
+func (t *TUI) statusLine() string {
+	if t.status == "" {
+		return ""
+	}
+	return bold + t.status + reset
+}
+
+func (t *TUI) helpLine() string {
+	switch t.mode {
+	case modeFilter:
+		return dim + "type to filter · enter done · esc clear" + reset
+	case modeDetail:
+		return dim + "↑↓ scroll · enter download · p preview · tab/esc back" + reset
+	}
+	return dim + "↑↓ move · / filter · tab details · p preview · enter download · q quit" + reset
+}
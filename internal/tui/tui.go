@@ -0,0 +1,409 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/xochilpili/subtitler-cli/internal/flags"
+	"github.com/xochilpili/subtitler-cli/internal/logger"
+	"github.com/xochilpili/subtitler-cli/internal/service"
+	"github.com/xochilpili/subtitler-cli/internal/subtitle"
+	"golang.org/x/term"
+)
+
+// previewCues is how many cues of the subtitle are shown by the preview.
+const previewCues = 8
+
+type mode int
+
+const (
+	modeList mode = iota
+	modeFilter
+	modeDetail
+)
+
+// TUI is a full screen browser of search results: a scrollable list, a live
+// filter, a detail pane with the comments and a preview of the subtitle.
+type TUI struct {
+	settings    *flags.OptionFlags
+	provider    service.Provider
+	subtitles   []service.Subtitles
+	highlighter *service.Highlighter
+
+	mode     mode
+	filter   string
+	visible  []int
+	cursor   int
+	offset   int
+	detail   int
+	status   string
+	preview  map[int][]subtitle.Cue
+	busy     bool
+	width    int
+	height   int
+	events   chan func()
+	quitting bool
+}
+
+func New(settings *flags.OptionFlags, provider service.Provider, subtitles []service.Subtitles) *TUI {
+	t := &TUI{
+		settings:    settings,
+		provider:    provider,
+		subtitles:   subtitles,
+		highlighter: service.NewHighlighter(settings.Releases),
+		preview:     map[int][]subtitle.Cue{},
+		events:      make(chan func(), 16),
+	}
+	t.applyFilter()
+	return t
+}
+
+// Run takes over the terminal until the user quits.
+func (t *TUI) Run() error {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return errors.New("the tui needs an interactive terminal")
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	fmt.Print(enterScreen)
+	defer fmt.Print(leaveScreen)
+	// the downloads log to stdout, which would scroll the screen
+	defer logger.SetOutput(logger.SetOutput(io.Discard))
+
+	keys := make(chan []keyPress)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- decodeKeys(buf[:n])
+		}
+	}()
+
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	t.resize()
+	t.render()
+	for !t.quitting {
+		select {
+		case pressed, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			for _, k := range pressed {
+				t.handle(k)
+			}
+		case fn := <-t.events:
+			fn()
+		case <-ticker.C:
+			if !t.resize() {
+				continue
+			}
+		}
+		t.render()
+	}
+	return nil
+}
+
+// resize reads the terminal size and reports whether it changed.
+func (t *TUI) resize() bool {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		width, height = 100, 30
+	}
+	changed := width != t.width || height != t.height
+	t.width, t.height = width, height
+	return changed
+}
+
+func (t *TUI) handle(k keyPress) {
+	if k.key == keyCtrlC {
+		t.quitting = true
+		return
+	}
+	switch t.mode {
+	case modeFilter:
+		t.handleFilter(k)
+	case modeDetail:
+		t.handleDetail(k)
+	default:
+		t.handleList(k)
+	}
+}
+
+func (t *TUI) handleList(k keyPress) {
+	switch k.key {
+	case keyUp:
+		t.move(-1)
+	case keyDown:
+		t.move(1)
+	case keyPageUp:
+		t.move(-t.listHeight())
+	case keyPageDown:
+		t.move(t.listHeight())
+	case keyHome:
+		t.move(-len(t.visible))
+	case keyEnd:
+		t.move(len(t.visible))
+	case keyEnter:
+		t.download()
+	case keyTab:
+		t.openDetail()
+	case keyEsc:
+		if t.filter != "" {
+			t.filter = ""
+			t.applyFilter()
+		}
+	case keyRune:
+		switch k.char {
+		case 'q':
+			t.quitting = true
+		case 'k':
+			t.move(-1)
+		case 'j':
+			t.move(1)
+		case 'g':
+			t.move(-len(t.visible))
+		case 'G':
+			t.move(len(t.visible))
+		case '/':
+			t.mode = modeFilter
+		case 'c', 'd':
+			t.openDetail()
+		case 'p':
+			t.loadPreview()
+		}
+	}
+}
+
+func (t *TUI) handleFilter(k keyPress) {
+	switch k.key {
+	case keyEnter:
+		t.mode = modeList
+	case keyEsc:
+		t.filter = ""
+		t.mode = modeList
+	case keyBackspace:
+		if r := []rune(t.filter); len(r) > 0 {
+			t.filter = string(r[:len(r)-1])
+		}
+	case keyCtrlU:
+		t.filter = ""
+	case keyUp:
+		t.move(-1)
+		return
+	case keyDown:
+		t.move(1)
+		return
+	case keyRune:
+		t.filter += string(k.char)
+	default:
+		return
+	}
+	t.applyFilter()
+}
+
+func (t *TUI) handleDetail(k keyPress) {
+	switch k.key {
+	case keyEsc, keyTab:
+		t.mode = modeList
+	case keyUp:
+		t.detail = max(t.detail-1, 0)
+	case keyDown:
+		t.detail++
+	case keyPageUp:
+		t.detail = max(t.detail-t.height/2, 0)
+	case keyPageDown:
+		t.detail += t.height / 2
+	case keyEnter:
+		t.download()
+	case keyRune:
+		switch k.char {
+		case 'q':
+			t.mode = modeList
+		case 'k':
+			t.detail = max(t.detail-1, 0)
+		case 'j':
+			t.detail++
+		case 'p':
+			t.loadPreview()
+		}
+	}
+}
+
+func (t *TUI) move(delta int) {
+	if len(t.visible) == 0 {
+		return
+	}
+	t.cursor = min(max(t.cursor+delta, 0), len(t.visible)-1)
+	t.detail = 0
+}
+
+// selected returns the subtitle under the cursor.
+func (t *TUI) selected() (*service.Subtitles, bool) {
+	if len(t.visible) == 0 {
+		return nil, false
+	}
+	return &t.subtitles[t.visible[t.cursor]], true
+}
+
+// applyFilter keeps the subtitles whose title, description or comments
+// contain every word of the filter.
+func (t *TUI) applyFilter() {
+	words := strings.Fields(strings.ToLower(t.filter))
+	t.visible = t.visible[:0]
+	for i, item := range t.subtitles {
+		haystack := strings.ToLower(item.Title + " " + item.Description)
+		if item.Comments != nil {
+			for _, comment := range *item.Comments {
+				haystack += " " + strings.ToLower(comment.Comment)
+			}
+		}
+		matches := true
+		for _, word := range words {
+			if !strings.Contains(haystack, word) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			t.visible = append(t.visible, i)
+		}
+	}
+	t.cursor = min(t.cursor, max(len(t.visible)-1, 0))
+	t.offset = 0
+}
+
+func (t *TUI) openDetail() {
+	selected, ok := t.selected()
+	if !ok {
+		return
+	}
+	t.mode = modeDetail
+	t.detail = 0
+	if selected.Comments == nil && t.settings.Comments != flags.CommentsNone {
+		t.loadComments(selected.Id)
+	}
+}
+
+// async runs fn outside of the event loop, the returned callback is applied
+// back on the loop so the state is only touched from there.
+func (t *TUI) async(status string, fn func(ctx context.Context) func()) {
+	if t.busy {
+		t.status = "busy, wait for the current operation"
+		return
+	}
+	t.busy = true
+	t.status = status
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		done := fn(ctx)
+		t.events <- func() {
+			t.busy = false
+			done()
+		}
+	}()
+}
+
+func (t *TUI) loadComments(id int) {
+	t.async("loading comments...", func(ctx context.Context) func() {
+		comments, err := t.provider.GetComments(ctx, id)
+		return func() {
+			if err != nil {
+				t.status = "error loading comments: " + err.Error()
+				return
+			}
+			for i := range t.subtitles {
+				if t.subtitles[i].Id == id {
+					t.subtitles[i].Comments = &comments
+				}
+			}
+			t.status = fmt.Sprintf("%d comments", len(comments))
+		}
+	})
+}
+
+// loadPreview downloads the archive into a temporary directory and keeps the
+// first cues of its first subtitle.
+func (t *TUI) loadPreview() {
+	selected, ok := t.selected()
+	if !ok {
+		return
+	}
+	id := selected.Id
+	if _, ok := t.preview[id]; ok {
+		t.mode = modeDetail
+		return
+	}
+	t.async("downloading preview...", func(ctx context.Context) func() {
+		cues, err := t.fetchPreview(ctx, id)
+		return func() {
+			if err != nil {
+				t.status = "error loading preview: " + err.Error()
+				return
+			}
+			t.preview[id] = cues
+			t.mode = modeDetail
+			t.detail = 0
+			t.status = "preview loaded"
+		}
+	})
+}
+
+func (t *TUI) fetchPreview(ctx context.Context, id int) ([]subtitle.Cue, error) {
+	tmp, err := os.MkdirTemp("", "subtitler-preview-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	files, err := t.provider.DownloadSubtitle(ctx, id, tmp)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) < 1 {
+		return nil, errors.New("the archive has no subtitles")
+	}
+	sub, _, err := subtitle.ReadFile(files[0].Path, t.settings.FPS)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Base(files[0].Path), err)
+	}
+	return sub.Cues[:min(previewCues, len(sub.Cues))], nil
+}
+
+func (t *TUI) download() {
+	selected, ok := t.selected()
+	if !ok {
+		return
+	}
+	id := selected.Id
+	t.async(fmt.Sprintf("downloading %d...", id), func(ctx context.Context) func() {
+		files, err := t.provider.DownloadSubtitle(ctx, id, t.settings.DownloadPath)
+		return func() {
+			if err != nil {
+				t.status = "error downloading: " + err.Error()
+				return
+			}
+			names := make([]string, len(files))
+			for i, file := range files {
+				names[i] = filepath.Base(file.Path)
+			}
+			t.status = fmt.Sprintf("downloaded %d: %s", id, strings.Join(names, ", "))
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMapKeepsOrder(t *testing.T) {
+	items := []int{5, 1, 4, 2, 3, 0}
+	var running, peak int32
+	results, errs := Map(context.Background(), 3, items, func(ctx context.Context, item int) (int, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			old := atomic.LoadInt32(&peak)
+			if n <= old || atomic.CompareAndSwapInt32(&peak, old, n) {
+				break
+			}
+		}
+		// later items finish first
+		time.Sleep(time.Duration(item) * time.Millisecond)
+		if item == 4 {
+			return 0, errors.New("four")
+		}
+		return item * 10, nil
+	})
+	for i, item := range items {
+		if item == 4 {
+			if errs[i] == nil {
+				t.Errorf("item %d: expected an error", item)
+			}
+			continue
+		}
+		if errs[i] != nil || results[i] != item*10 {
+			t.Errorf("item %d = %d, %v, want %d", item, results[i], errs[i], item*10)
+		}
+	}
+	if peak > 3 {
+		t.Errorf("ran %d at once, want at most 3", peak)
+	}
+}
+
+func TestMapCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var calls int32
+	_, errs := Map(ctx, 2, []int{1, 2, 3}, func(ctx context.Context, item int) (int, error) {
+		atomic.AddInt32(&calls, 1)
+		return item, nil
+	})
+	if calls != 0 {
+		t.Errorf("fn called %d times after cancel", calls)
+	}
+	for i, err := range errs {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("item %d error = %v, want context.Canceled", i, err)
+		}
+	}
+}